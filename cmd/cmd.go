@@ -21,11 +21,19 @@ var (
 		SilenceUsage:  true,
 		Version:       version.GetVersion(),
 	}
+	graphCmd = &cobra.Command{
+		Use:           "graph",
+		Short:         "Export the project dependency graph as DOT or Mermaid",
+		RunE:          graphRunE,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
 )
 
 // Init initializes the command line parser and executes the root command.
 func Init() {
 	initFlags(rootCmd)
+	initCommands(rootCmd)
 	err := rootCmd.Execute()
 	if err != nil {
 		fmt.Println(err)
@@ -47,6 +55,11 @@ func initFlags(cmd *cobra.Command) {
 	}
 }
 
+// initCommands registers the subcommands, which share the root command flags.
+func initCommands(cmd *cobra.Command) {
+	cmd.AddCommand(graphCmd)
+}
+
 // After command args are parsed in the above call, config.Init() function is invoked to check environment vars.
 // This approach enables to define args using either command-line or environment variables.
 
@@ -57,6 +70,17 @@ func runE(ccmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 
-	err = atlantis.GenerateAtlantisYAML()
+	err = atlantis.GenerateAtlantisYAML(atlantis.NewChangeProvider(config.GlobalConfig.Parameters["scm"]))
+	return err
+}
+
+// graphRunE is the execution of the graph command.
+func graphRunE(ccmd *cobra.Command, args []string) (err error) {
+	err = config.Init(ccmd)
+	if err != nil {
+		return err
+	}
+
+	err = atlantis.GenerateGraph(atlantis.NewChangeProvider(config.GlobalConfig.Parameters["scm"]))
 	return err
 }
@@ -20,3 +20,18 @@ func TestInitFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestInitCommands(t *testing.T) {
+	cmd := &cobra.Command{Use: "root"}
+	initFlags(cmd)
+	initCommands(cmd)
+
+	graph, _, err := cmd.Find([]string{"graph"})
+	if err != nil || graph.Name() != "graph" {
+		t.Errorf("Command graph was not initialized")
+	}
+	// Subcommands inherit the root command flags
+	if graph.InheritedFlags().Lookup("graph-format") == nil {
+		t.Errorf("Flag graph-format is not available to the graph command")
+	}
+}
@@ -0,0 +1,121 @@
+package atlantis
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// anchorProjectKeys are the project blocks declared once as an anchor and referenced
+// with aliases when several projects repeat them.
+var anchorProjectKeys = []string{"autoplan", "plan_requirements", "apply_requirements", "import_requirements"}
+
+// generatedAnchorPrefix is reserved for the anchors declared by the generator,
+// so they can't clash with the hand-written anchors kept in merge mode.
+const generatedAnchorPrefix = "generated_"
+
+// applyYAMLAnchors deduplicates the repeated project blocks of the config node.
+// Whole autoplan blocks are deduplicated first, then the when_modified lists of the
+// autoplan blocks left, as projects often only differ by the enabled flag.
+func applyYAMLAnchors(configNode *yaml.Node) error {
+	projects := mappingValue(configNode, projectsKey)
+	if projects == nil {
+		return nil
+	}
+	for _, key := range anchorProjectKeys {
+		err := anchorDuplicateValues(projects.Content, key)
+		if err != nil {
+			return err
+		}
+	}
+	var autoplans []*yaml.Node
+	for _, project := range projects.Content {
+		autoplan := mappingValue(project, "autoplan")
+		if autoplan != nil && autoplan.Kind == yaml.MappingNode {
+			autoplans = append(autoplans, autoplan)
+		}
+	}
+	return anchorDuplicateValues(autoplans, "when_modified")
+}
+
+// anchorDuplicateValues replaces the repeated values of a key in the given mappings
+// by aliases of their first occurrence, which is declared as an anchor named after the key.
+// Values already declared as an anchor are left alone.
+func anchorDuplicateValues(mappings []*yaml.Node, key string) error {
+	counts := map[string]int{}
+	for _, mapping := range mappings {
+		value := mappingValue(mapping, key)
+		if value == nil || value.Kind == yaml.AliasNode || value.Anchor != "" {
+			continue
+		}
+		content, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		counts[string(content)]++
+	}
+
+	anchors := map[string]*yaml.Node{}
+	for _, mapping := range mappings {
+		if mapping.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i < len(mapping.Content)-1; i += 2 {
+			value := mapping.Content[i+1]
+			if mapping.Content[i].Value != key || value.Kind == yaml.AliasNode || value.Anchor != "" {
+				continue
+			}
+			content, err := yaml.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if counts[string(content)] < 2 {
+				continue
+			}
+			anchor, found := anchors[string(content)]
+			if !found {
+				value.Anchor = fmt.Sprintf("%s%s%d", generatedAnchorPrefix, key, len(anchors)+1)
+				anchors[string(content)] = value
+				continue
+			}
+			mapping.Content[i+1] = &yaml.Node{Kind: yaml.AliasNode, Value: anchor.Anchor, Alias: anchor}
+		}
+	}
+	return nil
+}
+
+// checkGeneratedAnchors returns an error when the merged document declares or references
+// an anchor with the reserved prefix outside of the generated config, which would clash
+// with the generated anchors.
+func checkGeneratedAnchors(document, generated *yaml.Node) error {
+	generatedAnchors := map[*yaml.Node]bool{}
+	walkYAMLNodes(generated, func(node *yaml.Node) {
+		if node.Anchor != "" {
+			generatedAnchors[node] = true
+		}
+	})
+	var err error
+	walkYAMLNodes(document, func(node *yaml.Node) {
+		anchored := node
+		if node.Kind == yaml.AliasNode {
+			anchored = node.Alias
+		}
+		if err == nil && anchored != nil && strings.HasPrefix(anchored.Anchor, generatedAnchorPrefix) && !generatedAnchors[anchored] {
+			err = fmt.Errorf("anchor '%s' of the existing atlantis.yaml file uses the '%s' prefix reserved for the generated anchors, please rename it",
+				anchored.Anchor, generatedAnchorPrefix)
+		}
+	})
+	return err
+}
+
+// walkYAMLNodes calls fn on the node and all its descendants, without following aliases.
+func walkYAMLNodes(node *yaml.Node, fn func(node *yaml.Node)) {
+	if node == nil {
+		return
+	}
+	fn(node)
+	for _, child := range node.Content {
+		walkYAMLNodes(child, fn)
+	}
+}
@@ -0,0 +1,136 @@
+package atlantis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestApplyYAMLAnchors(t *testing.T) {
+	whenModified := []string{"**/*.tf", "**/*.tfvars"}
+	config := &Config{
+		Version: 3,
+		Projects: []Project{
+			{Name: "a", Dir: "a", Workspace: "default", Autoplan: Autoplan{Enabled: true, WhenModified: whenModified}},
+			{Name: "b", Dir: "b", Workspace: "default", Autoplan: Autoplan{Enabled: true, WhenModified: whenModified}},
+			{Name: "c", Dir: "c", Workspace: "default", Autoplan: Autoplan{Enabled: false, WhenModified: whenModified}},
+			{Name: "d", Dir: "d", Workspace: "default", Autoplan: Autoplan{Enabled: true, WhenModified: []string{"x"}}},
+		},
+	}
+
+	yamlBytes, err := renderOutputYAML(config, "", "false", "", "", "true")
+	assert.NoError(t, err)
+	assert.Equal(t, `version: 3
+automerge: false
+parallel_apply: false
+parallel_plan: false
+projects:
+    - name: a
+      workspace: default
+      dir: a
+      autoplan: &generated_autoplan1
+        enabled: true
+        when_modified: &generated_when_modified1
+            - '**/*.tf'
+            - '**/*.tfvars'
+    - name: b
+      workspace: default
+      dir: b
+      autoplan: *generated_autoplan1
+    - name: c
+      workspace: default
+      dir: c
+      autoplan:
+        enabled: false
+        when_modified: *generated_when_modified1
+    - name: d
+      workspace: default
+      dir: d
+      autoplan:
+        enabled: true
+        when_modified:
+            - x
+`, string(yamlBytes))
+
+	// Aliases resolve to the same config
+	var parsedConfig Config
+	err = yaml.Unmarshal(yamlBytes, &parsedConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, *config, parsedConfig)
+}
+
+func TestApplyYAMLAnchorsRequirements(t *testing.T) {
+	config := &Config{
+		Version: 3,
+		Projects: []Project{
+			{Name: "a", Dir: "a", Workspace: "default", Autoplan: Autoplan{Enabled: true, WhenModified: []string{"a/*"}}},
+			{Name: "b", Dir: "b", Workspace: "default", Autoplan: Autoplan{Enabled: true, WhenModified: []string{"b/*"}}},
+		},
+	}
+	configNode, err := generateConfigNode(config, `project_defaults:
+  apply_requirements: [approved, mergeable]
+`)
+	assert.NoError(t, err)
+	err = applyYAMLAnchors(configNode)
+	assert.NoError(t, err)
+
+	projects := mappingValue(configNode, projectsKey)
+	firstRequirements := mappingValue(projects.Content[0], "apply_requirements")
+	secondRequirements := mappingValue(projects.Content[1], "apply_requirements")
+	assert.Equal(t, "generated_apply_requirements1", firstRequirements.Anchor)
+	assert.Equal(t, yaml.AliasNode, secondRequirements.Kind)
+	assert.Equal(t, firstRequirements, secondRequirements.Alias)
+	// Unique blocks are left as they are
+	assert.Empty(t, mappingValue(projects.Content[0], "autoplan").Anchor)
+	assert.Equal(t, yaml.MappingNode, mappingValue(projects.Content[1], "autoplan").Kind)
+}
+
+func TestApplyYAMLAnchorsNoProjects(t *testing.T) {
+	configNode, err := generateConfigNode(&Config{Version: 3}, "")
+	assert.NoError(t, err)
+	assert.NoError(t, applyYAMLAnchors(configNode))
+}
+
+func TestApplyYAMLAnchorsMerge(t *testing.T) {
+	config := &Config{
+		Version: 3,
+		Projects: []Project{
+			{Name: "a", Dir: "a", Workspace: "default", Autoplan: Autoplan{Enabled: true, WhenModified: []string{"*.tf"}}},
+			{Name: "b", Dir: "b", Workspace: "default", Autoplan: Autoplan{Enabled: true, WhenModified: []string{"*.tf"}}},
+		},
+	}
+	outputFile := filepath.Join(t.TempDir(), "atlantis.yaml")
+
+	// Hand-written anchors are kept next to the generated ones
+	assert.NoError(t, os.WriteFile(outputFile, []byte(`version: 3
+projects:
+  - name: manual
+    dir: manual
+    autoplan: &autoplan1
+      enabled: false
+  - name: manual2
+    dir: manual2
+    autoplan: *autoplan1
+`), 0644))
+	yamlBytes, err := renderOutputYAML(config, outputFile, "true", "", "", "true")
+	assert.NoError(t, err)
+	var parsedConfig Config
+	assert.NoError(t, yaml.Unmarshal(yamlBytes, &parsedConfig))
+	assert.Len(t, parsedConfig.Projects, 4)
+	assert.False(t, parsedConfig.Projects[1].Autoplan.Enabled)
+	assert.True(t, parsedConfig.Projects[3].Autoplan.Enabled)
+
+	// Hand-written anchors can't use the reserved prefix
+	assert.NoError(t, os.WriteFile(outputFile, []byte(`version: 3
+projects:
+  - name: manual
+    dir: manual
+    autoplan: &generated_autoplan1
+      enabled: false
+`), 0644))
+	_, err = renderOutputYAML(config, outputFile, "true", "", "", "true")
+	assert.Error(t, err)
+}
@@ -8,9 +8,15 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/totmicro/atlantis-yaml-generator/pkg/azuredevops"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/bitbucket"
 	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/gitdiff"
 	"github.com/totmicro/atlantis-yaml-generator/pkg/github"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/gitlab"
 	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/version"
 
 	"gopkg.in/yaml.v3"
 )
@@ -26,14 +32,16 @@ type Config struct {
 }
 
 type Project struct {
-	Name      string `yaml:"name"`
-	Workspace string `yaml:"workspace"`
-	Workflow  string `yaml:"workflow,omitempty"`
-	Dir       string `yaml:"dir"`
-	Autoplan  struct {
-		Enabled      bool     `yaml:"enabled"`
-		WhenModified []string `yaml:"when_modified"`
-	} `yaml:"autoplan"`
+	Name      string   `yaml:"name"`
+	Workspace string   `yaml:"workspace"`
+	Workflow  string   `yaml:"workflow,omitempty"`
+	Dir       string   `yaml:"dir"`
+	Autoplan  Autoplan `yaml:"autoplan"`
+}
+
+type Autoplan struct {
+	Enabled      bool     `yaml:"enabled"`
+	WhenModified []string `yaml:"when_modified"`
 }
 
 type ProjectFolder struct {
@@ -41,85 +49,206 @@ type ProjectFolder struct {
 	WorkspaceList []string
 }
 
-// GenerateAtlantisYAML generates the atlantis.yaml file
-func GenerateAtlantisYAML() error {
+// GenerateAtlantisYAML generates the atlantis.yaml file, the PR changed files are listed by the change provider
+func GenerateAtlantisYAML(changeProvider scm.ChangeProvider) error {
 
-	// Check if the PR filter is enabled
-	enablePRFilter := config.GlobalConfig.Parameters["pr-filter"] == "true"
-
-	// Get the changed files from the PR if prFilter is enabled
-	var prChangedFiles []string
-	if enablePRFilter {
-		var err error
-		prChangedFiles, err = github.GetChangedFiles()
-		if err != nil {
-			return err
-		}
+	// Discover the projects selected by the PR filter and the included and excluded filters
+	discovery, err := discoverProjects(changeProvider)
+	if err != nil {
+		return err
 	}
+	report := discovery.Report
 
-	// Scan folders to detect projects
-	projectFoldersList, err := scanProjectFolders(
-		config.GlobalConfig.Parameters["terraform-base-dir"],
+	// Generate the when_modified list of each project
+	filteredAtlantisProjects, err := generateProjectsWhenModified(
 		config.GlobalConfig.Parameters["discovery-mode"],
 		config.GlobalConfig.Parameters["pattern-detector"],
-	)
+		config.GlobalConfig.Parameters["when-modified"],
+		config.GlobalConfig.Parameters["workspace-scoped-when-modified"],
+		discovery.Projects)
 	if err != nil {
 		return err
 	}
 
-	// Apply PR filter if enabled
-	if enablePRFilter {
-		projectFoldersList, err = applyPRFilter(projectFoldersList, prChangedFiles)
+	// Generate atlantis config to later render the atlantis.yaml file
+	atlantisConfig, err := generateAtlantisConfig(
+		config.GlobalConfig.Parameters["automerge"],
+		config.GlobalConfig.Parameters["parallel-apply"],
+		config.GlobalConfig.Parameters["parallel-plan"],
+		config.GlobalConfig.Parameters["when-modified"],
+		config.GlobalConfig.Parameters["autoplan-rules"],
+		filteredAtlantisProjects)
+	if err != nil {
+		return err
 	}
 
-	// Detect project workspaces
-	projectFoldersListWithWorkspaces, err := detectProjectWorkspaces(
-		projectFoldersList,
-		config.GlobalConfig.Parameters["discovery-mode"],
-		config.GlobalConfig.Parameters["pattern-detector"],
-		prChangedFiles, enablePRFilter)
+	// Render atlantis.yaml content
+	yamlBytes, err := renderOutputYAML(&atlantisConfig,
+		config.GlobalConfig.Parameters["output-file"],
+		config.GlobalConfig.Parameters["merge"],
+		config.GlobalConfig.Parameters["managed-project-prefix"],
+		config.GlobalConfig.Parameters["base-config"],
+		config.GlobalConfig.Parameters["yaml-anchors"])
 	if err != nil {
 		return err
 	}
 
-	// Generate atlantis projects
-	atlantisProjects, err := generateAtlantisProjects(
-		config.GlobalConfig.Parameters["workflow"],
-		projectFoldersListWithWorkspaces)
+	// Validate the rendered content against the Atlantis repo config spec if enabled
+	validate, err := strconv.ParseBool(config.GlobalConfig.Parameters["validate"])
 	if err != nil {
 		return err
 	}
+	if validate {
+		err = validateOutputYAML(yamlBytes,
+			config.GlobalConfig.Parameters["terraform-base-dir"],
+			config.GlobalConfig.Parameters["server-side-workflows"],
+			discovery.RemovedDirs)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Filter atlantis projects with included and excluded regex rules
-	filteredAtlantisProjects, err := applyProjectFilter(
-		config.GlobalConfig.Parameters["excluded-projects"],
-		config.GlobalConfig.Parameters["included-projects"],
-		atlantisProjects)
+	// Convert the rendered content to the output format
+	output, err := renderOutputFormat(yamlBytes, &atlantisConfig,
+		config.GlobalConfig.Parameters["output-format"],
+		config.GlobalConfig.Parameters["template"],
+		config.GlobalConfig.Parameters["matrix-shards"])
 	if err != nil {
 		return err
 	}
 
-	// Generate atlantis config to later render the atlantis.yaml file
-	atlantisConfig, err := generateAtlantisConfig(
-		config.GlobalConfig.Parameters["automerge"],
-		config.GlobalConfig.Parameters["parallel-apply"],
-		config.GlobalConfig.Parameters["parallel-plan"],
-		config.GlobalConfig.Parameters["when-modified"],
-		filteredAtlantisProjects)
+	// Prefix the output with the generated-file header if enabled
+	header, err := strconv.ParseBool(config.GlobalConfig.Parameters["header"])
+	if err != nil {
+		return err
+	}
+	if header {
+		// Only YAML output can carry the header comments
+		if config.GlobalConfig.Parameters["output-format"] != "yaml" {
+			return fmt.Errorf("header is not supported with output format '%s'",
+				config.GlobalConfig.Parameters["output-format"])
+		}
+		output = addOutputHeader(output, version.GetVersion(), headerParameters())
+	}
+
+	// Write the summary report if enabled
+	if config.GlobalConfig.Parameters["report-file"] != "" {
+		report.Projects = atlantisConfig.Projects
+		reportContent, err := renderReport(report, config.GlobalConfig.Parameters["report-format"])
+		if err != nil {
+			return err
+		}
+		err = writeReport(reportContent, config.GlobalConfig.Parameters["report-file"])
+		if err != nil {
+			return err
+		}
+	}
+
+	// In check mode, compare the rendered content with the existing file instead of writing it
+	check, err := strconv.ParseBool(config.GlobalConfig.Parameters["check"])
 	if err != nil {
 		return err
 	}
+	if check {
+		diff, err := checkOutput(output,
+			config.GlobalConfig.Parameters["output-file"],
+			config.GlobalConfig.Parameters["output-format"])
+		if err != nil {
+			return err
+		}
+		if diff != "" {
+			fmt.Print(diff)
+			return fmt.Errorf("%s is out of date, please regenerate it", config.GlobalConfig.Parameters["output-file"])
+		}
+		return nil
+	}
+
+	// Refuse to overwrite an output file edited since it was generated.
+	// Merge mode keeps hand-written sections by design, so edits are expected there.
+	if config.GlobalConfig.Parameters["output-type"] == "file" && config.GlobalConfig.Parameters["merge"] != "true" {
+		force, err := strconv.ParseBool(config.GlobalConfig.Parameters["force"])
+		if err != nil {
+			return err
+		}
+		existingContent, err := readExistingOutput(config.GlobalConfig.Parameters["output-file"])
+		if err != nil {
+			return err
+		}
+		err = checkHandEdited(existingContent, config.GlobalConfig.Parameters["output-file"], force)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Generate atlantis.yaml file
-	err = generateOutputYAML(&atlantisConfig,
+	// Write the output to its destination
+	err = writeOutput(output,
 		config.GlobalConfig.Parameters["output-file"],
-		config.GlobalConfig.Parameters["output-type"])
+		config.GlobalConfig.Parameters["output-type"],
+		config.GlobalConfig.Parameters["output-file-mode"])
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// NewChangeProvider returns the provider of the pull request changed files of the selected SCM.
+// Unsupported SCMs only fail when the changed files are listed, as they are not needed without PR filter.
+func NewChangeProvider(scmName string) scm.ChangeProvider {
+	switch scmName {
+	case "github":
+		return scm.ProviderFunc(github.GetChangedFiles)
+	case "gitlab":
+		return scm.ProviderFunc(gitlab.GetChangedFiles)
+	case "bitbucket-cloud":
+		return scm.ProviderFunc(bitbucket.GetCloudChangedFiles)
+	case "bitbucket-datacenter":
+		return scm.ProviderFunc(bitbucket.GetDataCenterChangedFiles)
+	case "azuredevops":
+		return scm.ProviderFunc(azuredevops.GetChangedFiles)
+	case "git":
+		return scm.ProviderFunc(gitdiff.GetChangedFiles)
+	case "file":
+		return scm.FileProvider{Path: config.GlobalConfig.Parameters["changed-files"]}
+	default:
+		return scm.ProviderFunc(func() ([]scm.ChangedFile, error) {
+			return nil, fmt.Errorf("scm '%s' is not supported", scmName)
+		})
+	}
+}
+
+// resolveAllProjectNames generates the projects of every discovered folder and workspace,
+// including the ones removed by the PR, and resolves their name collisions.
+// Atlantis rejects the whole file on duplicates.
+func resolveAllProjectNames(projectFolders []ProjectFolder, changedFiles []scm.ChangedFile,
+	mergeBasePaths map[string]bool) ([]Project, error) {
+	// Workspaces are detected on a copy, the detection updates the folders in place
+	allFolders := make([]ProjectFolder, len(projectFolders))
+	copy(allFolders, projectFolders)
+	allFolders, err := detectProjectWorkspaces(
+		allFolders,
+		config.GlobalConfig.Parameters["discovery-mode"],
+		config.GlobalConfig.Parameters["pattern-detector"],
+		nil, false)
+	if err != nil {
+		return nil, err
+	}
+	allFolders, _ = addRemovedProjectFolders(
+		allFolders,
+		changedFiles,
+		mergeBasePaths,
+		config.GlobalConfig.Parameters["discovery-mode"],
+		config.GlobalConfig.Parameters["pattern-detector"])
+	allProjects, err := generateAtlantisProjects(
+		config.GlobalConfig.Parameters["workflow"],
+		config.GlobalConfig.Parameters["workflow-rules"],
+		config.GlobalConfig.Parameters["project-name-template"],
+		allFolders)
+	if err != nil {
+		return nil, err
+	}
+	return resolveProjectCollisions(config.GlobalConfig.Parameters["name-collision-strategy"], allProjects)
+}
+
 func scanProjectFolders(basePath, discoveryMode, patternDetector string) (projectFolders []ProjectFolder, err error) {
 	err = filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil || info == nil {
@@ -160,17 +289,33 @@ func detectProjectWorkspaces(foldersList []ProjectFolder, discoveryMode string,
 	return updatedFoldersList, err
 }
 
-func generateAtlantisProjects(workflow string, projectFolderList []ProjectFolder) (projects []Project, err error) {
+func generateAtlantisProjects(workflow, workflowRules, nameTemplate string, projectFolderList []ProjectFolder) (projects []Project, err error) {
+	rules, err := parseProjectRules(workflowRules)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := parseProjectNameTemplate(nameTemplate)
+	if err != nil {
+		return nil, err
+	}
 	// Iterate over the project folders and generate atlantis projects
 	for _, folder := range projectFolderList {
 		for _, workspace := range folder.WorkspaceList {
-			name := genProjectName(folder.Path, workspace)
-			projects = append(projects, Project{
+			name, err := renderProjectName(tmpl, folder.Path, workspace)
+			if err != nil {
+				return nil, err
+			}
+			project := Project{
 				Name:      name,
 				Dir:       folder.Path,
 				Workspace: workspace,
 				Workflow:  workflow,
-			})
+			}
+			// The first matching rule overrides the default workflow
+			if ruleWorkflow, matched := matchProjectRules(rules, project); matched {
+				project.Workflow = ruleWorkflow
+			}
+			projects = append(projects, project)
 		}
 	}
 	return projects, nil
@@ -191,7 +336,27 @@ func applyProjectFilter(excludedProjects, includedProjects string, atlantisProje
 	return filteredAtlantisProjects, nil
 }
 
-func generateAtlantisConfig(autoMerge, parallelApply, parallelPlan, whenModified string, projects []Project) (Config, error) {
+func generateProjectsWhenModified(discoveryMode, patternDetector, whenModified, workspaceScoped string, projects []Project) ([]Project, error) {
+	scoped, err := strconv.ParseBool(workspaceScoped)
+	if err != nil {
+		return projects, err
+	}
+	whenmodified := strings.Split(whenModified, ",")
+	// Generate the when_modified list based on the discovery mode
+	for i := range projects {
+		switch {
+		case discoveryMode == "multi-workspace" && scoped:
+			projects[i].Autoplan.WhenModified = multiWorkspaceGenWhenModified(
+				whenmodified, patternDetector, projects[i].Workspace)
+		default:
+			projects[i].Autoplan.WhenModified = whenmodified
+		}
+	}
+	// You can add more discoveryMode rules here if required
+	return projects, nil
+}
+
+func generateAtlantisConfig(autoMerge, parallelApply, parallelPlan, whenModified, autoplanRules string, projects []Project) (Config, error) {
 	// Parse atlantis parameters to detect config values
 	automerge, err := strconv.ParseBool(autoMerge)
 	if err != nil {
@@ -206,6 +371,10 @@ func generateAtlantisConfig(autoMerge, parallelApply, parallelPlan, whenModified
 		return Config{}, err
 	}
 	whenmodified := strings.Split(whenModified, ",")
+	rules, err := parseAutoplanRules(autoplanRules)
+	if err != nil {
+		return Config{}, err
+	}
 	// Generate the atlantis base config
 	config := Config{
 		Version:       3,
@@ -215,17 +384,19 @@ func generateAtlantisConfig(autoMerge, parallelApply, parallelPlan, whenModified
 	}
 	// Append generated projects to the atlantis config
 	for _, info := range projects {
+		// Projects without their own when_modified list use the global one
+		projectWhenModified := info.Autoplan.WhenModified
+		if len(projectWhenModified) == 0 {
+			projectWhenModified = whenmodified
+		}
 		project := Project{
 			Name:      info.Name,
 			Workspace: info.Workspace,
 			Workflow:  info.Workflow,
 			Dir:       info.Dir,
-			Autoplan: struct {
-				Enabled      bool     `yaml:"enabled"`
-				WhenModified []string `yaml:"when_modified"`
-			}{
-				Enabled:      true,
-				WhenModified: whenmodified,
+			Autoplan: Autoplan{
+				Enabled:      autoplanEnabled(rules, info),
+				WhenModified: projectWhenModified,
 			},
 		}
 		config.Projects = append(config.Projects, project)
@@ -233,18 +404,70 @@ func generateAtlantisConfig(autoMerge, parallelApply, parallelPlan, whenModified
 	return config, err
 }
 
-func generateOutputYAML(config *Config, outputFile string, outputType string) error {
-	// Generate the atlantis.yaml file
-	yamlBytes, err := yaml.Marshal(&config)
+func renderOutputYAML(config *Config, outputFile, merge, managedPrefix, baseConfigFile, yamlAnchors string) ([]byte, error) {
+	mergeEnabled, err := strconv.ParseBool(merge)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	anchorsEnabled, err := strconv.ParseBool(yamlAnchors)
+	if err != nil {
+		return nil, err
+	}
+	// Use the base config file as skeleton of the generated config
+	var baseConfigYAML string
+	if baseConfigFile != "" {
+		baseConfigYAML, err = helpers.ReadFile(baseConfigFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	configNode, err := generateConfigNode(config, baseConfigYAML)
+	if err != nil {
+		return nil, err
+	}
+	// Declare the repeated project blocks once
+	if anchorsEnabled {
+		err = applyYAMLAnchors(configNode)
+		if err != nil {
+			return nil, err
+		}
 	}
+	// Generate the atlantis.yaml file
+	var yamlBytes []byte
+	if mergeEnabled {
+		// Keep the hand-written sections of the existing file
+		existingYAML, err := readExistingOutput(outputFile)
+		if err != nil {
+			return nil, err
+		}
+		// The generated-file header is added again once the output is rendered
+		_, existingYAML, err = parseOutputHeader(existingYAML)
+		if err != nil {
+			return nil, err
+		}
+		yamlBytes, err = mergeOutputYAML(configNode, existingYAML, managedPrefix)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		yamlBytes, err = yaml.Marshal(configNode)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return yamlBytes, nil
+}
+
+func writeOutput(content []byte, outputFile, outputType, outputFileMode string) error {
 	switch outputType {
 	case "file":
-		err = helpers.WriteFile(string(yamlBytes), outputFile)
-		return err
+		mode, err := strconv.ParseUint(outputFileMode, 8, 32)
+		if err != nil || mode > 0777 {
+			return fmt.Errorf("output file mode '%s' is not a valid octal permission", outputFileMode)
+		}
+		return helpers.WriteFileWithMode(string(content), outputFile, os.FileMode(mode))
 	case "stdout":
-		fmt.Printf(string(yamlBytes))
+		fmt.Print(string(content))
 		return nil
 	default:
 		return fmt.Errorf("output type '%s' is not supported", outputType)
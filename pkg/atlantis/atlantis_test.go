@@ -1,6 +1,7 @@
 package atlantis
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -9,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
 	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
 	"gopkg.in/yaml.v3"
 )
 
@@ -143,6 +145,7 @@ func TestGenerateAtlantisConfig(t *testing.T) {
 		parallelApply  string
 		parallelPlan   string
 		whenModified   string
+		autoplanRules  string
 		projects       []Project
 		expectedConfig Config
 		expectedError  bool
@@ -229,12 +232,81 @@ func TestGenerateAtlantisConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:          "Project when_modified",
+			autoMerge:     "true",
+			parallelApply: "false",
+			parallelPlan:  "true",
+			whenModified:  "*.tf,*.yaml",
+			projects: []Project{
+				{
+					Name:      "project1",
+					Workspace: "test",
+					Dir:       "dir1",
+					Autoplan:  Autoplan{WhenModified: []string{"*.tf", "vars/test.tfvars"}},
+				},
+			},
+			expectedConfig: Config{
+				Version:       3,
+				Automerge:     true,
+				ParallelApply: false,
+				ParallelPlan:  true,
+				Projects: []Project{
+					{
+						Name:      "project1",
+						Workspace: "test",
+						Dir:       "dir1",
+						Autoplan: Autoplan{
+							Enabled:      true,
+							WhenModified: []string{"*.tf", "vars/test.tfvars"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "Autoplan rules",
+			autoMerge:     "true",
+			parallelApply: "false",
+			parallelPlan:  "true",
+			whenModified:  "*.tf",
+			autoplanRules: "name:*-production=false;dir:legacy/**=false",
+			projects: []Project{
+				{Name: "app-staging", Workspace: "staging", Dir: "app"},
+				{Name: "app-production", Workspace: "production", Dir: "app"},
+				{Name: "legacy-app", Workspace: "default", Dir: "legacy/app"},
+			},
+			expectedConfig: Config{
+				Version:       3,
+				Automerge:     true,
+				ParallelApply: false,
+				ParallelPlan:  true,
+				Projects: []Project{
+					{Name: "app-staging", Workspace: "staging", Dir: "app",
+						Autoplan: Autoplan{Enabled: true, WhenModified: []string{"*.tf"}}},
+					{Name: "app-production", Workspace: "production", Dir: "app",
+						Autoplan: Autoplan{Enabled: false, WhenModified: []string{"*.tf"}}},
+					{Name: "legacy-app", Workspace: "default", Dir: "legacy/app",
+						Autoplan: Autoplan{Enabled: false, WhenModified: []string{"*.tf"}}},
+				},
+			},
+		},
+		{
+			name:          "Invalid autoplan rule value",
+			autoMerge:     "true",
+			parallelApply: "false",
+			parallelPlan:  "true",
+			whenModified:  "*.tf",
+			autoplanRules: "name:*-production=never",
+			projects:      []Project{},
+			expectedError: true,
+		},
 		// Add more test cases here with different values for automerge, parallelapply, parallelplan, and whenmodified
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			config, err := generateAtlantisConfig(test.autoMerge, test.parallelApply, test.parallelPlan, test.whenModified, test.projects)
+			config, err := generateAtlantisConfig(test.autoMerge, test.parallelApply, test.parallelPlan, test.whenModified, test.autoplanRules, test.projects)
 			if test.expectedError {
 				assert.Error(t, err)
 			} else {
@@ -245,6 +317,68 @@ func TestGenerateAtlantisConfig(t *testing.T) {
 	}
 }
 
+func TestGenerateProjectsWhenModified(t *testing.T) {
+	tests := []struct {
+		name                 string
+		discoveryMode        string
+		workspaceScoped      string
+		expectedWhenModified [][]string
+		expectedError        bool
+	}{
+		{
+			name:            "single-workspace",
+			discoveryMode:   "single-workspace",
+			workspaceScoped: "true",
+			expectedWhenModified: [][]string{
+				{"*.tf", "**/*.tfvars"},
+				{"*.tf", "**/*.tfvars"},
+			},
+		},
+		{
+			name:            "multi-workspace-scoped",
+			discoveryMode:   "multi-workspace",
+			workspaceScoped: "true",
+			expectedWhenModified: [][]string{
+				{"*.tf", "**/*.tfvars", "!workspace_vars/**", "workspace_vars/**/staging.tfvars"},
+				{"*.tf", "**/*.tfvars", "!workspace_vars/**", "workspace_vars/**/production.tfvars"},
+			},
+		},
+		{
+			name:            "multi-workspace-not-scoped",
+			discoveryMode:   "multi-workspace",
+			workspaceScoped: "false",
+			expectedWhenModified: [][]string{
+				{"*.tf", "**/*.tfvars"},
+				{"*.tf", "**/*.tfvars"},
+			},
+		},
+		{
+			name:            "unparsable-scoped",
+			discoveryMode:   "multi-workspace",
+			workspaceScoped: "yes",
+			expectedError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			projects := []Project{
+				{Name: "app-staging", Dir: "app", Workspace: "staging"},
+				{Name: "app-production", Dir: "app", Workspace: "production"},
+			}
+			projects, err := generateProjectsWhenModified(test.discoveryMode, "workspace_vars", "*.tf,**/*.tfvars", test.workspaceScoped, projects)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			for i, project := range projects {
+				assert.Equal(t, test.expectedWhenModified[i], project.Autoplan.WhenModified)
+			}
+		})
+	}
+}
+
 func TestGenerateAtlantisProjects(t *testing.T) {
 	projectFolders := []ProjectFolder{
 		{
@@ -286,7 +420,7 @@ func TestGenerateAtlantisProjects(t *testing.T) {
 		},
 	}
 
-	projects, err := generateAtlantisProjects(workflow, projectFolders)
+	projects, err := generateAtlantisProjects(workflow, "", "", projectFolders)
 
 	if err != nil {
 		t.Errorf("Expected no error, but got: %v", err)
@@ -295,6 +429,28 @@ func TestGenerateAtlantisProjects(t *testing.T) {
 	if !reflect.DeepEqual(projects, expectedProjects) {
 		t.Errorf("Expected projects: %+v, but got: %+v", expectedProjects, projects)
 	}
+
+	// Rules override the default workflow, first match wins
+	workflowRules := "workspace:prod=prodWorkflow;dir~^project2$=project2Workflow"
+	expectedProjects[1].Workflow = "myWorkflow"
+	expectedProjects[2].Workflow = "project2Workflow"
+	expectedProjects[3].Workflow = "prodWorkflow"
+
+	projects, err = generateAtlantisProjects(workflow, workflowRules, "", projectFolders)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedProjects, projects)
+
+	_, err = generateAtlantisProjects(workflow, "invalid-rule", "", projectFolders)
+	assert.Error(t, err)
+
+	// Templated names replace the default naming convention
+	projects, err = generateAtlantisProjects(workflow, "", "{{ .Dir }}__{{ .Workspace }}", projectFolders)
+	assert.NoError(t, err)
+	assert.Equal(t, "project1__default", projects[0].Name)
+	assert.Equal(t, "project2__prod", projects[3].Name)
+
+	_, err = generateAtlantisProjects(workflow, "", "{{ .Unknown }}", projectFolders)
+	assert.Error(t, err)
 }
 
 func TestPrFilter(t *testing.T) {
@@ -660,11 +816,20 @@ func TestGenerateOutputYAML(t *testing.T) {
 	outputFile := "/tmp/test_output.yaml"
 	outputType := "file"
 
-	// Call the function and generate the YAML
-	err := generateOutputYAML(config, outputFile, outputType)
+	// Call the functions to render and write the YAML
+	yamlBytes, err := renderOutputYAML(config, outputFile, "false", "", "", "false")
+	if err != nil {
+		t.Errorf("Error rendering output YAML: %v", err)
+	}
+	err = writeOutput(yamlBytes, outputFile, outputType, "0644")
 	if err != nil {
 		t.Errorf("Error generating output YAML: %v", err)
 	}
+	// Invalid output file modes are rejected
+	err = writeOutput(yamlBytes, outputFile, outputType, "rw-r--r--")
+	if err == nil {
+		t.Errorf("Expected an error for an invalid output file mode")
+	}
 	// Read the generated YAML and unmarshal it for comparison
 	generatedYAML, err := helpers.ReadFile(outputFile)
 	if err != nil {
@@ -699,24 +864,107 @@ func TestGenerateAtlantisYAML(t *testing.T) {
 	config.GlobalConfig.Parameters["terraform-base-dir"] = "mockproject"
 	config.GlobalConfig.Parameters["output-file"] = tempFile
 	config.GlobalConfig.Parameters["output-type"] = "file"
+	config.GlobalConfig.Parameters["output-file-mode"] = "0644"
 	config.GlobalConfig.Parameters["parallel-apply"] = "true"
 	config.GlobalConfig.Parameters["parallel-plan"] = "true"
 	config.GlobalConfig.Parameters["automerge"] = "true"
 	config.GlobalConfig.Parameters["pr-filter"] = "false"
+	config.GlobalConfig.Parameters["name-collision-strategy"] = "fail"
+	config.GlobalConfig.Parameters["workspace-scoped-when-modified"] = "true"
+	config.GlobalConfig.Parameters["merge"] = "false"
+	config.GlobalConfig.Parameters["validate"] = "false"
+	config.GlobalConfig.Parameters["check"] = "false"
+	config.GlobalConfig.Parameters["output-format"] = "yaml"
+	config.GlobalConfig.Parameters["header"] = "false"
+	config.GlobalConfig.Parameters["yaml-anchors"] = "false"
+	config.GlobalConfig.Parameters["force"] = "false"
+
+	err := GenerateAtlantisYAML(NewChangeProvider("github"))
+	assert.NoError(t, err)
+
+	// The file was just generated, so it is up to date
+	config.GlobalConfig.Parameters["check"] = "true"
+	err = GenerateAtlantisYAML(NewChangeProvider("github"))
+	assert.NoError(t, err)
 
-	err := GenerateAtlantisYAML()
+	config.GlobalConfig.Parameters["automerge"] = "false"
+	err = GenerateAtlantisYAML(NewChangeProvider("github"))
+	assert.Error(t, err)
+	config.GlobalConfig.Parameters["automerge"] = "true"
+	config.GlobalConfig.Parameters["check"] = "false"
+
+	// With a header, hand edits are detected and only overwritten when forced
+	config.GlobalConfig.Parameters["header"] = "true"
+	err = GenerateAtlantisYAML(NewChangeProvider("github"))
+	assert.NoError(t, err)
+	generatedContent, err := os.ReadFile(tempFile)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(generatedContent), "test-token")
+	err = os.WriteFile(tempFile, append(generatedContent, []byte("# hand edit\n")...), 0644)
+	assert.NoError(t, err)
+	err = GenerateAtlantisYAML(NewChangeProvider("github"))
+	assert.Error(t, err)
+	config.GlobalConfig.Parameters["force"] = "true"
+	err = GenerateAtlantisYAML(NewChangeProvider("github"))
 	assert.NoError(t, err)
+	config.GlobalConfig.Parameters["force"] = "false"
+	config.GlobalConfig.Parameters["header"] = "false"
 
 	os.Remove(tempFile)
 
 	config.GlobalConfig.Parameters["output-type"] = "undefined"
 
-	err = GenerateAtlantisYAML()
+	err = GenerateAtlantisYAML(NewChangeProvider("github"))
 	assert.Error(t, err)
 
 	config.GlobalConfig.Parameters["output-type"] = "stdout"
 
-	err = GenerateAtlantisYAML()
+	err = GenerateAtlantisYAML(NewChangeProvider("github"))
+	assert.NoError(t, err)
+
+	// The PR filter lists the changed files from the change provider
+	config.GlobalConfig.Parameters["pr-filter"] = "true"
+	config.GlobalConfig.Parameters["output-type"] = "file"
+	config.GlobalConfig.Parameters["discovery-mode"] = "single-workspace"
+	changedFilesPath := filepath.Join(tempDir, "changed-files.txt")
+	err = os.WriteFile(changedFilesPath, []byte("M\tsingleworkspace/main.tf\n"), 0644)
+	assert.NoError(t, err)
+	err = GenerateAtlantisYAML(scm.FileProvider{Path: changedFilesPath})
+	assert.NoError(t, err)
+	generatedContent, err = os.ReadFile(tempFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(generatedContent), "dir: singleworkspace\n")
+	assert.NotContains(t, string(generatedContent), "dir: singleworkspace2")
+
+	// Removed stacks which can't be confirmed on the merge-base get no project
+	config.GlobalConfig.Parameters["validate"] = "true"
+	config.GlobalConfig.Parameters["server-side-workflows"] = "workflow1"
+	err = GenerateAtlantisYAML(scm.ProviderFunc(func() ([]scm.ChangedFile, error) {
+		return []scm.ChangedFile{{Path: "removed/main.tf", Status: scm.StatusRemoved}}, nil
+	}))
+	assert.NoError(t, err)
+	generatedContent, err = os.ReadFile(tempFile)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(generatedContent), "dir: removed\n")
+	config.GlobalConfig.Parameters["validate"] = "false"
+
+	err = GenerateAtlantisYAML(scm.ProviderFunc(func() ([]scm.ChangedFile, error) {
+		return nil, fmt.Errorf("provider error")
+	}))
+	assert.Error(t, err)
+}
+
+func TestNewChangeProvider(t *testing.T) {
+	changedFilesPath := filepath.Join(t.TempDir(), "changed-files.txt")
+	err := os.WriteFile(changedFilesPath, []byte("R100\told/main.tf\tnew/main.tf\n"), 0644)
+	assert.NoError(t, err)
+	config.GlobalConfig.Parameters = map[string]string{"changed-files": changedFilesPath}
+
+	changedFiles, err := NewChangeProvider("file").ChangedFiles()
 	assert.NoError(t, err)
+	assert.Equal(t, []scm.ChangedFile{{Path: "new/main.tf", Status: scm.StatusRenamed, PreviousPath: "old/main.tf"}}, changedFiles)
 
+	// Unsupported SCMs only fail when listing the changed files
+	_, err = NewChangeProvider("svn").ChangedFiles()
+	assert.EqualError(t, err, "scm 'svn' is not supported")
 }
@@ -0,0 +1,93 @@
+package atlantis
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// projectDefaultsKey is the base config key holding the fields added to every generated project.
+// It is not an Atlantis setting, so it is removed from the output.
+const projectDefaultsKey = "project_defaults"
+
+// generateConfigNode renders the config as a YAML node, using the base config as skeleton if provided.
+func generateConfigNode(config *Config, baseConfigYAML string) (*yaml.Node, error) {
+	var generated yaml.Node
+	err := generated.Encode(config)
+	if err != nil {
+		return nil, err
+	}
+	if baseConfigYAML == "" {
+		return &generated, nil
+	}
+	return applyBaseConfig(&generated, baseConfigYAML)
+}
+
+// applyBaseConfig injects the generated projects into the base config.
+// Base config keys take precedence over the generated ones, which are only added when missing.
+func applyBaseConfig(generated *yaml.Node, baseConfigYAML string) (*yaml.Node, error) {
+	var document yaml.Node
+	err := yaml.Unmarshal([]byte(baseConfigYAML), &document)
+	if err != nil {
+		return nil, fmt.Errorf("parsing base config: %w", err)
+	}
+	if len(document.Content) == 0 {
+		return generated, nil
+	}
+	base := document.Content[0]
+	if base.Kind != yaml.MappingNode {
+		return nil, errors.New("base config is not a YAML mapping")
+	}
+	if mappingValue(base, projectsKey) != nil {
+		return nil, errors.New("base config must not define projects, they are generated (use merge mode to keep hand-written projects)")
+	}
+
+	projectDefaults := mappingValue(base, projectDefaultsKey)
+	if projectDefaults != nil && projectDefaults.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("base config %s is not a YAML mapping", projectDefaultsKey)
+	}
+	deleteMappingKey(base, projectDefaultsKey)
+
+	for i := 0; i < len(generated.Content)-1; i += 2 {
+		key, value := generated.Content[i], generated.Content[i+1]
+		if key.Value == projectsKey && projectDefaults != nil {
+			for _, project := range value.Content {
+				applyProjectDefaults(project, projectDefaults)
+			}
+		}
+		if mappingValue(base, key.Value) == nil {
+			base.Content = append(base.Content, key, value)
+		}
+	}
+	return base, nil
+}
+
+// applyProjectDefaults adds the default fields not already set in the generated project.
+func applyProjectDefaults(project, projectDefaults *yaml.Node) {
+	for i := 0; i < len(projectDefaults.Content)-1; i += 2 {
+		key, value := projectDefaults.Content[i], projectDefaults.Content[i+1]
+		if mappingValue(project, key.Value) == nil {
+			project.Content = append(project.Content, copyNode(key), copyNode(value))
+		}
+	}
+}
+
+func deleteMappingKey(mapping *yaml.Node, key string) {
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// copyNode returns a deep copy of a YAML node, so it can be modified independently.
+func copyNode(node *yaml.Node) *yaml.Node {
+	nodeCopy := *node
+	nodeCopy.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		nodeCopy.Content[i] = copyNode(child)
+	}
+	return &nodeCopy
+}
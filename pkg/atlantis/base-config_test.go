@@ -0,0 +1,140 @@
+package atlantis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateConfigNode(t *testing.T) {
+	config := &Config{
+		Version:       3,
+		Automerge:     true,
+		ParallelApply: true,
+		ParallelPlan:  true,
+		Projects: []Project{
+			{Name: "app", Workspace: "default", Dir: "app", Workflow: "custom", Autoplan: Autoplan{Enabled: true, WhenModified: []string{"*.tf"}}},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		baseConfigYAML string
+		expectedYAML   string
+		expectedError  bool
+	}{
+		{
+			name:           "NoBaseConfig",
+			baseConfigYAML: "",
+			expectedYAML: `version: 3
+automerge: true
+parallel_apply: true
+parallel_plan: true
+projects:
+    - name: app
+      workspace: default
+      workflow: custom
+      dir: app
+      autoplan:
+        enabled: true
+        when_modified:
+            - '*.tf'
+`,
+		},
+		{
+			name: "BaseConfigSkeleton",
+			baseConfigYAML: `version: 3
+automerge: false # Set by the base config
+delete_source_branch_on_merge: true
+allowed_regexp_prefixes:
+    - lock/
+project_defaults:
+    workflow: default
+    apply_requirements:
+        - approved
+workflows:
+    custom:
+        plan:
+            steps:
+                - init
+`,
+			expectedYAML: `version: 3
+automerge: false # Set by the base config
+delete_source_branch_on_merge: true
+allowed_regexp_prefixes:
+    - lock/
+workflows:
+    custom:
+        plan:
+            steps:
+                - init
+parallel_apply: true
+parallel_plan: true
+projects:
+    - name: app
+      workspace: default
+      workflow: custom
+      dir: app
+      autoplan:
+        enabled: true
+        when_modified:
+            - '*.tf'
+      apply_requirements:
+        - approved
+`,
+		},
+		{
+			name:           "BaseConfigWithProjects",
+			baseConfigYAML: "projects:\n  - name: manual\n",
+			expectedError:  true,
+		},
+		{
+			name:           "InvalidProjectDefaults",
+			baseConfigYAML: "project_defaults: [workflow]\n",
+			expectedError:  true,
+		},
+		{
+			name:           "BaseConfigNotAMapping",
+			baseConfigYAML: "- version\n",
+			expectedError:  true,
+		},
+		{
+			name:           "InvalidBaseConfig",
+			baseConfigYAML: "version: [3\n",
+			expectedError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			configNode, err := generateConfigNode(config, tc.baseConfigYAML)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			configYAML, err := yaml.Marshal(configNode)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedYAML, string(configYAML))
+		})
+	}
+}
+
+func TestRenderOutputYAMLBaseConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	outputFile := filepath.Join(tempDir, "atlantis.yaml")
+	baseConfigFile := filepath.Join(tempDir, "base.yaml")
+	err := helpers.WriteFile("delete_source_branch_on_merge: true\n", baseConfigFile)
+	assert.NoError(t, err)
+	config := &Config{Version: 3}
+
+	generatedYAML, err := renderOutputYAML(config, outputFile, "false", "", baseConfigFile, "false")
+	assert.NoError(t, err)
+	assert.Contains(t, string(generatedYAML), "delete_source_branch_on_merge: true\n")
+
+	_, err = renderOutputYAML(config, outputFile, "false", "", filepath.Join(tempDir, "missing.yaml"), "false")
+	assert.Error(t, err)
+}
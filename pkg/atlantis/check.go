@@ -0,0 +1,81 @@
+package atlantis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+// checkOutput compares the rendered content with the existing output file.
+// YAML and JSON outputs are compared semantically, ignoring comments, key order and project order,
+// while other formats are compared as text.
+// It returns a unified diff of both documents, or nothing if they are equivalent.
+func checkOutput(content []byte, outputFile, outputFormat string) (string, error) {
+	existingContent, err := readExistingOutput(outputFile)
+	if err != nil {
+		return "", err
+	}
+	existing, generated := existingContent, string(content)
+	if outputFormat == "yaml" || outputFormat == "json" {
+		// JSON documents are valid YAML documents too
+		existing, err = normalizeOutputYAML([]byte(existingContent))
+		if err != nil {
+			return "", fmt.Errorf("parsing %s: %w", outputFile, err)
+		}
+		generated, err = normalizeOutputYAML(content)
+		if err != nil {
+			return "", err
+		}
+	}
+	if existing == generated {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(existing),
+		B:        splitLines(generated),
+		FromFile: outputFile,
+		ToFile:   "generated",
+		Context:  3,
+	})
+}
+
+// normalizeOutputYAML renders a YAML document in a canonical form:
+// mapping keys are sorted and projects are sorted by name, dir and workspace.
+func normalizeOutputYAML(content []byte) (string, error) {
+	var document map[string]interface{}
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		return "", err
+	}
+	if projects, ok := document[projectsKey].([]interface{}); ok {
+		sort.SliceStable(projects, func(i, j int) bool {
+			return projectSortKey(projects[i]) < projectSortKey(projects[j])
+		})
+	}
+	// Empty documents are rendered as an empty string instead of "{}"
+	if len(document) == 0 {
+		return "", nil
+	}
+	normalized, err := yaml.Marshal(document)
+	return string(normalized), err
+}
+
+func projectSortKey(project interface{}) string {
+	fields, ok := project.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%v\x00%v\x00%v", fields["name"], fields["dir"], fields["workspace"])
+}
+
+// splitLines splits a text into lines keeping their line breaks.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
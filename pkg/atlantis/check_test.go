@@ -0,0 +1,95 @@
+package atlantis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+)
+
+func TestCheckOutput(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "atlantis.yaml")
+	existingYAML := `# Committed file
+version: 3
+projects:
+    - name: b
+      dir: b
+    - dir: a
+      name: a
+`
+	err := helpers.WriteFile(existingYAML, outputFile)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		content      string
+		expectedDiff string
+	}{
+		{
+			name: "OrderingNoise",
+			content: `version: 3
+projects:
+    - name: a
+      dir: a
+    - name: b
+      dir: b
+`,
+			expectedDiff: "",
+		},
+		{
+			name: "Different",
+			content: `version: 3
+projects:
+    - name: a
+      dir: a
+`,
+			expectedDiff: "--- " + outputFile + `
++++ generated
+@@ -1,6 +1,4 @@
+ projects:
+     - dir: a
+       name: a
+-    - dir: b
+-      name: b
+ version: 3
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := checkOutput([]byte(tc.content), outputFile, "yaml")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedDiff, diff)
+		})
+	}
+}
+
+func TestCheckOutputMissingFile(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "atlantis.yaml")
+
+	diff, err := checkOutput([]byte("version: 3\n"), outputFile, "yaml")
+	assert.NoError(t, err)
+	assert.Contains(t, diff, "+version: 3")
+
+	err = helpers.WriteFile("version: [3", outputFile)
+	assert.NoError(t, err)
+	_, err = checkOutput([]byte("version: 3\n"), outputFile, "yaml")
+	assert.Error(t, err)
+
+	// Template outputs are compared as text
+	diff, err = checkOutput([]byte("version: [3"), outputFile, "template")
+	assert.NoError(t, err)
+	assert.Equal(t, "", diff)
+}
+
+func TestCheckOutputJSON(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "atlantis.json")
+	err := helpers.WriteFile(`{"projects": [{"name": "b"}, {"name": "a"}], "version": 3}`, outputFile)
+	assert.NoError(t, err)
+
+	diff, err := checkOutput([]byte(`{"version": 3, "projects": [{"name": "a"}, {"name": "b"}]}`), outputFile, "json")
+	assert.NoError(t, err)
+	assert.Equal(t, "", diff)
+}
@@ -0,0 +1,138 @@
+package atlantis
+
+import (
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+// projectDiscovery holds the projects found in the terraform base dir, shared by the
+// atlantis.yaml generation and the graph.
+type projectDiscovery struct {
+	// AllProjects are all the discovered projects, including the ones removed by the PR,
+	// kept by the included and excluded filters
+	AllProjects []Project
+	// Projects are the projects also kept by the PR filter
+	Projects []Project
+	// ChangedFiles are the paths changed by the PR, before and after renames
+	ChangedFiles []string
+	// RemovedDirs are the project folders removed by the PR, confirmed on its merge-base
+	RemovedDirs []string
+	// Report records the projects dropped by the filters
+	Report *Report
+}
+
+// discoverProjects scans the terraform base dir for projects and applies the PR filter,
+// when enabled, and the included and excluded filters.
+func discoverProjects(changeProvider scm.ChangeProvider) (*projectDiscovery, error) {
+
+	// Check if the PR filter is enabled
+	enablePRFilter := config.GlobalConfig.Parameters["pr-filter"] == "true"
+
+	// Get the changed files from the PR if prFilter is enabled
+	var changedFiles []scm.ChangedFile
+	if enablePRFilter {
+		var err error
+		changedFiles, err = changeProvider.ChangedFiles()
+		if err != nil {
+			return nil, err
+		}
+	}
+	discovery := &projectDiscovery{ChangedFiles: scm.Paths(changedFiles), Report: &Report{}}
+
+	// Scan folders to detect projects
+	projectFoldersList, err := scanProjectFolders(
+		config.GlobalConfig.Parameters["terraform-base-dir"],
+		config.GlobalConfig.Parameters["discovery-mode"],
+		config.GlobalConfig.Parameters["pattern-detector"],
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Confirm on the merge-base the stacks removed by the PR
+	var mergeBasePaths map[string]bool
+	if enablePRFilter {
+		mergeBasePaths = mergeBaseRemovedPaths(changedFiles,
+			config.GlobalConfig.Parameters["discovery-mode"],
+			config.GlobalConfig.Parameters["pattern-detector"])
+	}
+
+	// Resolve name collisions on all the discovered projects, so a project gets the same
+	// name whatever the PR filter and the included and excluded filters keep
+	resolvedProjects, err := resolveAllProjectNames(projectFoldersList, changedFiles, mergeBasePaths)
+	if err != nil {
+		return nil, err
+	}
+	discovery.AllProjects, err = applyProjectFilter(
+		config.GlobalConfig.Parameters["excluded-projects"],
+		config.GlobalConfig.Parameters["included-projects"],
+		resolvedProjects)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply PR filter if enabled
+	if enablePRFilter {
+		prFilteredFoldersList, err := applyPRFilter(projectFoldersList, discovery.ChangedFiles)
+		if err != nil {
+			return nil, err
+		}
+		discovery.Report.addPRFilterDroppedFolders(projectFoldersList, prFilteredFoldersList)
+		projectFoldersList = prFilteredFoldersList
+	}
+
+	// Detect project workspaces
+	projectFoldersListWithWorkspaces, err := detectProjectWorkspaces(
+		projectFoldersList,
+		config.GlobalConfig.Parameters["discovery-mode"],
+		config.GlobalConfig.Parameters["pattern-detector"],
+		discovery.ChangedFiles, enablePRFilter)
+	if err != nil {
+		return nil, err
+	}
+	if enablePRFilter {
+		err = discovery.Report.addPRFilterDroppedWorkspaces(
+			config.GlobalConfig.Parameters["discovery-mode"],
+			config.GlobalConfig.Parameters["pattern-detector"],
+			projectFoldersListWithWorkspaces,
+			resolvedProjects)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Add the projects removed by the PR, the scan can't find them anymore
+	if enablePRFilter {
+		projectFoldersListWithWorkspaces, discovery.RemovedDirs = addRemovedProjectFolders(
+			projectFoldersListWithWorkspaces,
+			changedFiles,
+			mergeBasePaths,
+			config.GlobalConfig.Parameters["discovery-mode"],
+			config.GlobalConfig.Parameters["pattern-detector"])
+	}
+
+	// Generate atlantis projects
+	atlantisProjects, err := generateAtlantisProjects(
+		config.GlobalConfig.Parameters["workflow"],
+		config.GlobalConfig.Parameters["workflow-rules"],
+		config.GlobalConfig.Parameters["project-name-template"],
+		projectFoldersListWithWorkspaces)
+	if err != nil {
+		return nil, err
+	}
+
+	atlantisProjects = applyResolvedProjectNames(atlantisProjects, resolvedProjects)
+
+	// Filter atlantis projects with included and excluded regex rules
+	discovery.Projects, err = applyProjectFilter(
+		config.GlobalConfig.Parameters["excluded-projects"],
+		config.GlobalConfig.Parameters["included-projects"],
+		atlantisProjects)
+	if err != nil {
+		return nil, err
+	}
+	discovery.Report.addProjectFilterDropped(atlantisProjects, discovery.Projects,
+		config.GlobalConfig.Parameters["excluded-projects"],
+		config.GlobalConfig.Parameters["included-projects"])
+	return discovery, nil
+}
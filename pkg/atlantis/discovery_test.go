@@ -0,0 +1,43 @@
+package atlantis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+func TestDiscoverProjects(t *testing.T) {
+	config.GlobalConfig.Parameters = map[string]string{
+		"pr-filter":               "true",
+		"terraform-base-dir":      "mockproject",
+		"discovery-mode":          "single-workspace",
+		"pattern-detector":        "main.tf",
+		"project-name-template":   "{{ .Dir }}",
+		"name-collision-strategy": "fail",
+		"excluded-projects":       "^singleworkspace2$",
+	}
+	changeProvider := scm.ProviderFunc(func() ([]scm.ChangedFile, error) {
+		return []scm.ChangedFile{
+			{Path: "singleworkspace/main.tf", Status: scm.StatusModified},
+			{Path: "removed/main.tf", Status: scm.StatusRemoved},
+		}, nil
+	})
+
+	discovery, err := discoverProjects(changeProvider)
+	assert.NoError(t, err)
+	var allProjects, projects []string
+	for _, project := range discovery.AllProjects {
+		allProjects = append(allProjects, project.Name)
+	}
+	for _, project := range discovery.Projects {
+		projects = append(projects, project.Name)
+	}
+	// Removed projects can't be confirmed without the merge-base, the PR filter still applies
+	assert.Equal(t, []string{"singleworkspace"}, allProjects)
+	assert.Equal(t, []string{"singleworkspace"}, projects)
+	assert.Empty(t, discovery.RemovedDirs)
+	assert.Equal(t, []string{"singleworkspace/main.tf", "removed/main.tf"}, discovery.ChangedFiles)
+	assert.NotEmpty(t, discovery.Report.DroppedProjects)
+}
@@ -0,0 +1,404 @@
+package atlantis
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	graphNodeProject = "project"
+	graphNodeModule  = "module"
+
+	graphEdgeModule      = "module"
+	graphEdgeRemoteState = "remote_state"
+	graphEdgeDependsOn   = "depends_on"
+)
+
+// projectGraph holds the discovered projects, the local modules they use and the
+// dependencies between them. Edges go from the dependent node to its dependency.
+type projectGraph struct {
+	Nodes []graphNode
+	Edges []graphEdge
+}
+
+type graphNode struct {
+	ID      string
+	Label   string
+	Kind    string
+	Touched bool
+}
+
+type graphEdge struct {
+	From string
+	To   string
+	Kind string
+}
+
+// terraformReferences are the references to other Terraform configurations found in a folder.
+type terraformReferences struct {
+	ModuleSources []string
+	RemoteStates  []remoteStateReference
+}
+
+type remoteStateReference struct {
+	Backend   string
+	Workspace string
+	Config    map[string]string
+}
+
+// GenerateGraph renders the dependency graph of the discovered projects.
+// All projects are part of the graph, including the ones removed by the PR,
+// the PR filter only highlights the touched ones.
+func GenerateGraph(changeProvider scm.ChangeProvider) error {
+	discovery, err := discoverProjects(changeProvider)
+	if err != nil {
+		return err
+	}
+
+	// Highlight the projects the PR filter keeps
+	touchedProjects := map[string]bool{}
+	if config.GlobalConfig.Parameters["pr-filter"] == "true" {
+		for _, project := range discovery.Projects {
+			touchedProjects[projectDirWorkspace(project)] = true
+		}
+	}
+
+	// Explicit depends_on are read from the projects of the existing output file
+	existingOutput, err := readExistingOutput(config.GlobalConfig.Parameters["output-file"])
+	if err != nil {
+		return err
+	}
+	_, existingOutput, err = parseOutputHeader(existingOutput)
+	if err != nil {
+		return err
+	}
+
+	graph, err := buildProjectGraph(config.GlobalConfig.Parameters["terraform-base-dir"],
+		discovery.AllProjects, touchedProjects, discovery.ChangedFiles, existingOutput)
+	if err != nil {
+		return err
+	}
+	content, err := renderGraph(graph, config.GlobalConfig.Parameters["graph-format"])
+	if err != nil {
+		return err
+	}
+	fmt.Print(content)
+	return nil
+}
+
+// buildProjectGraph infers the edges between projects from their local module sources,
+// terraform_remote_state data sources and the depends_on of the existing output.
+func buildProjectGraph(baseDir string, projects []Project, touchedProjects map[string]bool,
+	changedFiles []string, existingOutput string) (*projectGraph, error) {
+
+	graph := &projectGraph{}
+	nodes := map[string]bool{}
+	edges := map[graphEdge]bool{}
+	addEdge := func(edge graphEdge) {
+		if edge.From != edge.To && !edges[edge] {
+			edges[edge] = true
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+
+	for _, project := range projects {
+		nodes[project.Name] = true
+		graph.Nodes = append(graph.Nodes, graphNode{
+			ID:      project.Name,
+			Label:   project.Name,
+			Kind:    graphNodeProject,
+			Touched: touchedProjects[projectDirWorkspace(project)],
+		})
+	}
+
+	// Projects sharing a folder share its references, so each folder is parsed once
+	references := map[string]terraformReferences{}
+	folderReferences := func(dir string) (terraformReferences, error) {
+		refs, found := references[dir]
+		if found {
+			return refs, nil
+		}
+		refs, err := parseTerraformReferences(filepath.Join(baseDir, dir))
+		references[dir] = refs
+		return refs, err
+	}
+
+	var moduleDirs []string
+	var scanModule func(moduleDir string) error
+	scanModule = func(moduleDir string) error {
+		if nodes[moduleNodeID(moduleDir)] {
+			return nil
+		}
+		nodes[moduleNodeID(moduleDir)] = true
+		moduleDirs = append(moduleDirs, moduleDir)
+		refs, err := folderReferences(moduleDir)
+		if err != nil {
+			return err
+		}
+		for _, source := range refs.ModuleSources {
+			nestedModuleDir := path.Join(moduleDir, source)
+			err = scanModule(nestedModuleDir)
+			if err != nil {
+				return err
+			}
+			addEdge(graphEdge{From: moduleNodeID(moduleDir), To: moduleNodeID(nestedModuleDir), Kind: graphEdgeModule})
+		}
+		return nil
+	}
+
+	for _, project := range projects {
+		dir := filepath.ToSlash(project.Dir)
+		refs, err := folderReferences(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range refs.ModuleSources {
+			moduleDir := path.Join(dir, source)
+			err = scanModule(moduleDir)
+			if err != nil {
+				return nil, err
+			}
+			addEdge(graphEdge{From: project.Name, To: moduleNodeID(moduleDir), Kind: graphEdgeModule})
+		}
+		for _, remoteState := range refs.RemoteStates {
+			for _, target := range remoteStateTargets(project, remoteState, projects) {
+				addEdge(graphEdge{From: project.Name, To: target.Name, Kind: graphEdgeRemoteState})
+			}
+		}
+	}
+
+	var existingConfig validationConfig
+	err := yaml.Unmarshal([]byte(existingOutput), &existingConfig)
+	if err != nil {
+		return nil, fmt.Errorf("parsing existing atlantis.yaml file: %w", err)
+	}
+	for _, project := range existingConfig.Projects {
+		for _, dependency := range project.DependsOn {
+			if nodes[project.Name] && nodes[dependency] {
+				addEdge(graphEdge{From: project.Name, To: dependency, Kind: graphEdgeDependsOn})
+			}
+		}
+	}
+
+	sort.Strings(moduleDirs)
+	for _, moduleDir := range moduleDirs {
+		graph.Nodes = append(graph.Nodes, graphNode{
+			ID:      moduleNodeID(moduleDir),
+			Label:   moduleDir,
+			Kind:    graphNodeModule,
+			Touched: prFilter(moduleDir, changedFiles),
+		})
+	}
+
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+	return graph, nil
+}
+
+func moduleNodeID(moduleDir string) string {
+	return graphNodeModule + ":" + moduleDir
+}
+
+// remoteStateTargets returns the projects whose state is read by a terraform_remote_state data source.
+// The state path, or key for remote backends, is matched against the project folders.
+func remoteStateTargets(project Project, remoteState remoteStateReference, projects []Project) []Project {
+	var stateDir string
+	if remoteState.Backend == "local" {
+		statePath := remoteState.Config["path"]
+		if statePath == "" {
+			return nil
+		}
+		stateDir = path.Dir(path.Join(filepath.ToSlash(project.Dir), statePath))
+	} else {
+		stateDir = remoteState.Config["key"]
+		if stateDir == "" {
+			stateDir = remoteState.Config["prefix"]
+		}
+		if strings.HasSuffix(stateDir, ".tfstate") {
+			stateDir = path.Dir(stateDir)
+		}
+		stateDir = strings.Trim(stateDir, "/")
+	}
+	if stateDir == "" || stateDir == "." {
+		return nil
+	}
+
+	// State keys are often prefixed, so the longest folder ending the key is used
+	var targetDir string
+	for _, candidate := range projects {
+		dir := filepath.ToSlash(candidate.Dir)
+		if (stateDir == dir || strings.HasSuffix(stateDir, "/"+dir)) && len(dir) > len(targetDir) {
+			targetDir = dir
+		}
+	}
+	var targets, sameWorkspaceTargets []Project
+	for _, candidate := range projects {
+		if filepath.ToSlash(candidate.Dir) != targetDir || candidate.Dir == project.Dir {
+			continue
+		}
+		if remoteState.Workspace != "" && candidate.Workspace != remoteState.Workspace {
+			continue
+		}
+		targets = append(targets, candidate)
+		if candidate.Workspace == project.Workspace {
+			sameWorkspaceTargets = append(sameWorkspaceTargets, candidate)
+		}
+	}
+	// Without an explicit workspace, a project reads the state of its own workspace when it exists
+	if remoteState.Workspace == "" && len(sameWorkspaceTargets) > 0 {
+		return sameWorkspaceTargets
+	}
+	return targets
+}
+
+// parseTerraformReferences reads the local module sources and terraform_remote_state
+// data sources of the Terraform files in a folder. Only literal values are resolved.
+// Files which can't be parsed are skipped with a warning, so the rest of the graph is still built.
+func parseTerraformReferences(dir string) (references terraformReferences, err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return references, err
+	}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		hclFile, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			fmt.Fprintf(os.Stderr, "WARNING: skipping %s in the graph, it can't be parsed: %s\n", file, diags.Error())
+			continue
+		}
+		body, ok := hclFile.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		for _, block := range body.Blocks {
+			switch {
+			case block.Type == "module":
+				source, ok := literalString(block.Body.Attributes["source"])
+				if ok && (strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")) {
+					references.ModuleSources = append(references.ModuleSources, source)
+				}
+			case block.Type == "data" && len(block.Labels) > 0 && block.Labels[0] == "terraform_remote_state":
+				references.RemoteStates = append(references.RemoteStates, parseRemoteState(block.Body))
+			}
+		}
+	}
+	return references, nil
+}
+
+func parseRemoteState(body *hclsyntax.Body) remoteStateReference {
+	remoteState := remoteStateReference{Config: map[string]string{}}
+	remoteState.Backend, _ = literalString(body.Attributes["backend"])
+	remoteState.Workspace, _ = literalString(body.Attributes["workspace"])
+	configAttribute := body.Attributes["config"]
+	if configAttribute == nil {
+		return remoteState
+	}
+	pairs, diags := hcl.ExprMap(configAttribute.Expr)
+	if diags.HasErrors() {
+		return remoteState
+	}
+	for _, pair := range pairs {
+		key, keyOk := literalStringExpr(pair.Key)
+		value, valueOk := literalStringExpr(pair.Value)
+		if keyOk && valueOk {
+			remoteState.Config[key] = value
+		}
+	}
+	return remoteState
+}
+
+func literalString(attribute *hclsyntax.Attribute) (string, bool) {
+	if attribute == nil {
+		return "", false
+	}
+	return literalStringExpr(attribute.Expr)
+}
+
+// literalStringExpr evaluates an expression without variables, so references are ignored.
+func literalStringExpr(expr hcl.Expression) (string, bool) {
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() || !value.IsKnown() || value.IsNull() || value.Type() != cty.String {
+		return "", false
+	}
+	return value.AsString(), true
+}
+
+func renderGraph(graph *projectGraph, graphFormat string) (string, error) {
+	switch graphFormat {
+	case "dot":
+		return renderGraphDOT(graph), nil
+	case "mermaid":
+		return renderGraphMermaid(graph), nil
+	default:
+		return "", fmt.Errorf("graph format '%s' is not supported", graphFormat)
+	}
+}
+
+func renderGraphDOT(graph *projectGraph) string {
+	var content strings.Builder
+	content.WriteString("digraph atlantis {\n")
+	content.WriteString("  rankdir=LR;\n")
+	content.WriteString("  node [shape=box];\n")
+	for _, node := range graph.Nodes {
+		attributes := []string{"label=" + strconv.Quote(node.Label)}
+		if node.Kind == graphNodeModule {
+			attributes = append(attributes, "shape=ellipse")
+		}
+		if node.Touched {
+			attributes = append(attributes, "style=filled", `fillcolor="#ffb347"`)
+		}
+		content.WriteString(fmt.Sprintf("  %s [%s];\n", strconv.Quote(node.ID), strings.Join(attributes, ", ")))
+	}
+	for _, edge := range graph.Edges {
+		content.WriteString(fmt.Sprintf("  %s -> %s [label=%s];\n",
+			strconv.Quote(edge.From), strconv.Quote(edge.To), strconv.Quote(edge.Kind)))
+	}
+	content.WriteString("}\n")
+	return content.String()
+}
+
+func renderGraphMermaid(graph *projectGraph) string {
+	// Mermaid node ids can't hold every character of project names, so they are numbered
+	ids := map[string]string{}
+	var touched []string
+	var content strings.Builder
+	content.WriteString("flowchart LR\n")
+	for i, node := range graph.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[node.ID] = id
+		label := strings.ReplaceAll(node.Label, `"`, "#quot;")
+		if node.Kind == graphNodeModule {
+			content.WriteString(fmt.Sprintf("    %s([\"%s\"])\n", id, label))
+		} else {
+			content.WriteString(fmt.Sprintf("    %s[\"%s\"]\n", id, label))
+		}
+		if node.Touched {
+			touched = append(touched, id)
+		}
+	}
+	for _, edge := range graph.Edges {
+		content.WriteString(fmt.Sprintf("    %s -->|%s| %s\n", ids[edge.From], edge.Kind, ids[edge.To]))
+	}
+	if len(touched) > 0 {
+		content.WriteString("    classDef touched fill:#ffb347,stroke:#333\n")
+		content.WriteString(fmt.Sprintf("    class %s touched\n", strings.Join(touched, ",")))
+	}
+	return content.String()
+}
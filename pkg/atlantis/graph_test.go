@@ -0,0 +1,245 @@
+package atlantis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+)
+
+func writeGraphFixture(t *testing.T) string {
+	baseDir := t.TempDir()
+	files := map[string]string{
+		"network/main.tf": `module "vpc" {
+  source = "../modules/vpc"
+}
+module "registry" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+`,
+		"app/main.tf": `data "terraform_remote_state" "network" {
+  backend = "s3"
+  config = {
+    bucket = "states"
+    key    = "prod/network/terraform.tfstate"
+  }
+}
+module "service" {
+  source = "./../modules/service"
+}
+`,
+		"db/main.tf": `data "terraform_remote_state" "app" {
+  backend = "local"
+  config = {
+    path = "../app/terraform.tfstate"
+  }
+}
+data "terraform_remote_state" "dynamic" {
+  backend = "s3"
+  config = {
+    key = "${var.env}/network/terraform.tfstate"
+  }
+}
+`,
+		"modules/service/main.tf": `module "vpc" {
+  source = "../vpc"
+}
+`,
+		"modules/vpc/main.tf": `resource "null_resource" "vpc" {}
+`,
+	}
+	for name, content := range files {
+		filePath := filepath.Join(baseDir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		assert.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+	}
+	return baseDir
+}
+
+func TestBuildProjectGraph(t *testing.T) {
+	baseDir := writeGraphFixture(t)
+	projects := []Project{
+		{Name: "app", Dir: "app", Workspace: "default"},
+		{Name: "db", Dir: "db", Workspace: "default"},
+		{Name: "network", Dir: "network", Workspace: "default"},
+	}
+	touchedProjects := map[string]bool{"app@default": true}
+	changedFiles := []string{"app/main.tf", "modules/vpc/main.tf"}
+	existingOutput := `version: 3
+projects:
+  - name: db
+    dir: db
+    depends_on: [network, unknown]
+`
+
+	graph, err := buildProjectGraph(baseDir, projects, touchedProjects, changedFiles, existingOutput)
+	assert.NoError(t, err)
+	assert.Equal(t, []graphNode{
+		{ID: "app", Label: "app", Kind: "project", Touched: true},
+		{ID: "db", Label: "db", Kind: "project"},
+		{ID: "network", Label: "network", Kind: "project"},
+		{ID: "module:modules/service", Label: "modules/service", Kind: "module"},
+		{ID: "module:modules/vpc", Label: "modules/vpc", Kind: "module", Touched: true},
+	}, graph.Nodes)
+	assert.Equal(t, []graphEdge{
+		{From: "app", To: "module:modules/service", Kind: "module"},
+		{From: "app", To: "network", Kind: "remote_state"},
+		{From: "db", To: "app", Kind: "remote_state"},
+		{From: "db", To: "network", Kind: "depends_on"},
+		{From: "module:modules/service", To: "module:modules/vpc", Kind: "module"},
+		{From: "network", To: "module:modules/vpc", Kind: "module"},
+	}, graph.Edges)
+
+	// Invalid Terraform files are skipped, the rest of the graph is still built
+	assert.NoError(t, os.WriteFile(filepath.Join(baseDir, "db", "broken.tf"), []byte("module {"), 0644))
+	graph, err = buildProjectGraph(baseDir, projects, touchedProjects, changedFiles, "")
+	assert.NoError(t, err)
+	assert.Contains(t, graph.Edges, graphEdge{From: "db", To: "app", Kind: "remote_state"})
+}
+
+func TestRemoteStateTargets(t *testing.T) {
+	projects := []Project{
+		{Name: "app-dev", Dir: "app", Workspace: "dev"},
+		{Name: "app-prod", Dir: "app", Workspace: "prod"},
+		{Name: "network-dev", Dir: "network", Workspace: "dev"},
+		{Name: "network-prod", Dir: "network", Workspace: "prod"},
+		{Name: "core-network", Dir: "core/network", Workspace: "default"},
+	}
+
+	testCases := []struct {
+		name            string
+		project         Project
+		remoteState     remoteStateReference
+		expectedTargets []string
+	}{
+		{
+			name:            "SameWorkspace",
+			project:         projects[0],
+			remoteState:     remoteStateReference{Backend: "s3", Config: map[string]string{"key": "network/terraform.tfstate"}},
+			expectedTargets: []string{"network-dev"},
+		},
+		{
+			name:    "ExplicitWorkspace",
+			project: projects[0],
+			remoteState: remoteStateReference{Backend: "s3", Workspace: "prod",
+				Config: map[string]string{"key": "network/terraform.tfstate"}},
+			expectedTargets: []string{"network-prod"},
+		},
+		{
+			name:            "LongestFolderMatch",
+			project:         projects[0],
+			remoteState:     remoteStateReference{Backend: "gcs", Config: map[string]string{"prefix": "states/core/network"}},
+			expectedTargets: []string{"core-network"},
+		},
+		{
+			name:            "AllWorkspaces",
+			project:         projects[4],
+			remoteState:     remoteStateReference{Backend: "s3", Config: map[string]string{"key": "app/terraform.tfstate"}},
+			expectedTargets: []string{"app-dev", "app-prod"},
+		},
+		{
+			name:        "UnknownState",
+			project:     projects[0],
+			remoteState: remoteStateReference{Backend: "s3", Config: map[string]string{"key": "terraform.tfstate"}},
+		},
+		{
+			name:        "OwnFolder",
+			project:     projects[0],
+			remoteState: remoteStateReference{Backend: "local", Config: map[string]string{"path": "terraform.tfstate"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var targets []string
+			for _, target := range remoteStateTargets(tc.project, tc.remoteState, projects) {
+				targets = append(targets, target.Name)
+			}
+			assert.Equal(t, tc.expectedTargets, targets)
+		})
+	}
+}
+
+func TestRenderGraph(t *testing.T) {
+	graph := &projectGraph{
+		Nodes: []graphNode{
+			{ID: "app", Label: "app", Kind: "project", Touched: true},
+			{ID: "network", Label: "network", Kind: "project"},
+			{ID: "module:modules/vpc", Label: "modules/vpc", Kind: "module"},
+		},
+		Edges: []graphEdge{
+			{From: "app", To: "network", Kind: "remote_state"},
+			{From: "network", To: "module:modules/vpc", Kind: "module"},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		graphFormat    string
+		expectedOutput string
+		expectedError  bool
+	}{
+		{
+			name:        "DOT",
+			graphFormat: "dot",
+			expectedOutput: `digraph atlantis {
+  rankdir=LR;
+  node [shape=box];
+  "app" [label="app", style=filled, fillcolor="#ffb347"];
+  "network" [label="network"];
+  "module:modules/vpc" [label="modules/vpc", shape=ellipse];
+  "app" -> "network" [label="remote_state"];
+  "network" -> "module:modules/vpc" [label="module"];
+}
+`,
+		},
+		{
+			name:        "Mermaid",
+			graphFormat: "mermaid",
+			expectedOutput: `flowchart LR
+    n0["app"]
+    n1["network"]
+    n2(["modules/vpc"])
+    n0 -->|remote_state| n1
+    n1 -->|module| n2
+    classDef touched fill:#ffb347,stroke:#333
+    class n0 touched
+`,
+		},
+		{
+			name:          "UnsupportedFormat",
+			graphFormat:   "svg",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := renderGraph(graph, tc.graphFormat)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedOutput, output)
+		})
+	}
+}
+
+func TestGenerateGraph(t *testing.T) {
+	config.GlobalConfig.Parameters = map[string]string{
+		"pr-filter":               "false",
+		"terraform-base-dir":      "mockproject",
+		"discovery-mode":          "single-workspace",
+		"pattern-detector":        "main.tf",
+		"name-collision-strategy": "fail",
+		"output-file":             filepath.Join(t.TempDir(), "atlantis.yaml"),
+		"graph-format":            "mermaid",
+	}
+	assert.NoError(t, GenerateGraph(NewChangeProvider("github")))
+
+	config.GlobalConfig.Parameters["graph-format"] = "svg"
+	assert.Error(t, GenerateGraph(NewChangeProvider("github")))
+}
@@ -0,0 +1,121 @@
+package atlantis
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+)
+
+// The generated-file header is a block of YAML comments on top of the output:
+//
+//	# generated by atlantis-yaml-generator 0.0.3 — do not edit
+//	# parameter: automerge="true"
+//	# content-sha256: 0123abcd...
+//
+// The hash covers the content below the header, so a later run can tell whether
+// the file was edited by hand since it was generated.
+const (
+	headerGeneratedPrefix = "# generated by atlantis-yaml-generator "
+	headerGeneratedSuffix = " — do not edit"
+	headerParameterPrefix = "# parameter: "
+	headerHashPrefix      = "# content-sha256: "
+)
+
+type outputHeader struct {
+	Version     string
+	Parameters  map[string]string
+	ContentHash string
+}
+
+// addOutputHeader prefixes the content with the generated-file header.
+// Parameters are written in name order, with quoted values so multi-line ones fit in a comment.
+func addOutputHeader(content []byte, version string, parameters map[string]string) []byte {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var header strings.Builder
+	header.WriteString(headerGeneratedPrefix + version + headerGeneratedSuffix + "\n")
+	for _, name := range names {
+		header.WriteString(fmt.Sprintf("%s%s=%s\n", headerParameterPrefix, name, strconv.Quote(parameters[name])))
+	}
+	header.WriteString(headerHashPrefix + contentHash(string(content)) + "\n")
+	return append([]byte(header.String()), content...)
+}
+
+// parseOutputHeader splits the content into its header and body.
+// The header is nil when the content doesn't start with one.
+func parseOutputHeader(content string) (*outputHeader, string, error) {
+	if !strings.HasPrefix(content, headerGeneratedPrefix) {
+		return nil, content, nil
+	}
+	header := &outputHeader{Parameters: map[string]string{}}
+	rest := content
+	for rest != "" {
+		line := rest
+		lineEnd := strings.Index(rest, "\n")
+		if lineEnd != -1 {
+			line = rest[:lineEnd]
+			rest = rest[lineEnd+1:]
+		} else {
+			rest = ""
+		}
+		switch {
+		case strings.HasPrefix(line, headerGeneratedPrefix):
+			header.Version = strings.TrimSuffix(strings.TrimPrefix(line, headerGeneratedPrefix), headerGeneratedSuffix)
+		case strings.HasPrefix(line, headerParameterPrefix):
+			name, value, found := strings.Cut(strings.TrimPrefix(line, headerParameterPrefix), "=")
+			unquotedValue, err := strconv.Unquote(value)
+			if !found || err != nil {
+				return nil, content, fmt.Errorf("invalid generated-file header line '%s'", line)
+			}
+			header.Parameters[name] = unquotedValue
+		case strings.HasPrefix(line, headerHashPrefix):
+			// The hash line closes the header
+			header.ContentHash = strings.TrimPrefix(line, headerHashPrefix)
+			return header, rest, nil
+		default:
+			return nil, content, fmt.Errorf("invalid generated-file header line '%s'", line)
+		}
+	}
+	return nil, content, errors.New("generated-file header has no content hash")
+}
+
+// checkHandEdited returns an error when the existing output has a generated-file header
+// whose hash doesn't match its content anymore, unless forced.
+func checkHandEdited(existingContent, outputFile string, force bool) error {
+	header, body, err := parseOutputHeader(existingContent)
+	if err != nil {
+		return fmt.Errorf("output file %s: %w", outputFile, err)
+	}
+	if header == nil || force || header.ContentHash == contentHash(body) {
+		return nil
+	}
+	return fmt.Errorf("output file %s was edited since it was generated, use --force to overwrite it", outputFile)
+}
+
+func contentHash(content string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+}
+
+// headerParameters returns the parameters written to the header: only the ones set explicitly,
+// leaving out the secret and per-run ones, so the header doesn't change from one pull request to the next.
+func headerParameters() map[string]string {
+	parameters := map[string]string{}
+	for _, parameter := range config.ParameterList {
+		value := config.GlobalConfig.Parameters[parameter.Name]
+		if parameter.Secret || parameter.PerRun || value == "" ||
+			!config.GlobalConfig.ExplicitParameters[parameter.Name] {
+			continue
+		}
+		parameters[parameter.Name] = value
+	}
+	return parameters
+}
@@ -0,0 +1,117 @@
+package atlantis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+)
+
+func TestAddOutputHeader(t *testing.T) {
+	content := []byte("version: 3\n")
+	output := addOutputHeader(content, "1.2.3", map[string]string{
+		"workflow":  "default",
+		"automerge": "true",
+		"template":  "{{ range .Projects }}\n{{ end }}",
+	})
+	assert.Equal(t, `# generated by atlantis-yaml-generator 1.2.3 — do not edit
+# parameter: automerge="true"
+# parameter: template="{{ range .Projects }}\n{{ end }}"
+# parameter: workflow="default"
+# content-sha256: `+contentHash("version: 3\n")+`
+version: 3
+`, string(output))
+
+	// The header is parsed back
+	header, body, err := parseOutputHeader(string(output))
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 3\n", body)
+	assert.Equal(t, &outputHeader{
+		Version: "1.2.3",
+		Parameters: map[string]string{
+			"automerge": "true",
+			"template":  "{{ range .Projects }}\n{{ end }}",
+			"workflow":  "default",
+		},
+		ContentHash: contentHash("version: 3\n"),
+	}, header)
+}
+
+func TestParseOutputHeader(t *testing.T) {
+	testCases := []struct {
+		name           string
+		content        string
+		expectedHeader bool
+		expectedBody   string
+		expectedError  bool
+	}{
+		{
+			name:         "NoHeader",
+			content:      "# hand-written\nversion: 3\n",
+			expectedBody: "# hand-written\nversion: 3\n",
+		},
+		{
+			name:           "EmptyBody",
+			content:        "# generated by atlantis-yaml-generator 1.2.3 — do not edit\n# content-sha256: abc",
+			expectedHeader: true,
+			expectedBody:   "",
+		},
+		{
+			name:          "MissingHash",
+			content:       "# generated by atlantis-yaml-generator 1.2.3 — do not edit\n# parameter: a=\"b\"\n",
+			expectedError: true,
+		},
+		{
+			name:          "InvalidParameter",
+			content:       "# generated by atlantis-yaml-generator 1.2.3 — do not edit\n# parameter: a=b\n# content-sha256: abc\n",
+			expectedError: true,
+		},
+		{
+			name:          "UnexpectedLine",
+			content:       "# generated by atlantis-yaml-generator 1.2.3 — do not edit\nversion: 3\n",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header, body, err := parseOutputHeader(tc.content)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedHeader, header != nil)
+			assert.Equal(t, tc.expectedBody, body)
+		})
+	}
+}
+
+func TestCheckHandEdited(t *testing.T) {
+	generated := string(addOutputHeader([]byte("version: 3\n"), "1.2.3", nil))
+
+	assert.NoError(t, checkHandEdited("", "atlantis.yaml", false))
+	assert.NoError(t, checkHandEdited("version: 3\n", "atlantis.yaml", false))
+	assert.NoError(t, checkHandEdited(generated, "atlantis.yaml", false))
+
+	edited := generated + "automerge: true\n"
+	assert.Error(t, checkHandEdited(edited, "atlantis.yaml", false))
+	assert.NoError(t, checkHandEdited(edited, "atlantis.yaml", true))
+}
+
+func TestHeaderParameters(t *testing.T) {
+	config.GlobalConfig.Parameters = map[string]string{
+		"gh-token":       "secret",
+		"workflow":       "default",
+		"automerge":      "",
+		"discovery-mode": "single-workspace",
+		"pull-num":       "42",
+	}
+	config.GlobalConfig.ExplicitParameters = map[string]bool{
+		"gh-token":  true,
+		"workflow":  true,
+		"automerge": true,
+		"pull-num":  true,
+	}
+	assert.Equal(t, map[string]string{"workflow": "default"}, headerParameters())
+}
@@ -0,0 +1,52 @@
+package atlantis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// MatrixEntry is a project as exposed to CI matrix jobs.
+// The shard is only set when the projects are sharded.
+type MatrixEntry struct {
+	Name      string `json:"name"`
+	Dir       string `json:"dir"`
+	Workspace string `json:"workspace"`
+	Workflow  string `json:"workflow"`
+	Shard     *int   `json:"shard,omitempty"`
+}
+
+// renderMatrix renders the projects as a JSON array of entries suitable for a CI matrix.
+// With N shards, projects are assigned round-robin to N shards, recorded in the shard field
+// of every entry. There are never more shards than projects, so no shard is empty.
+// No projects render an empty array.
+func renderMatrix(config *Config, matrixShards string) ([]byte, error) {
+	shards, err := strconv.Atoi(matrixShards)
+	if err != nil || shards < 0 {
+		return nil, fmt.Errorf("matrix shards '%s' must be zero or a positive number", matrixShards)
+	}
+	if shards > len(config.Projects) {
+		shards = len(config.Projects)
+	}
+
+	entries := []MatrixEntry{}
+	for i, project := range config.Projects {
+		entry := MatrixEntry{
+			Name:      project.Name,
+			Dir:       project.Dir,
+			Workspace: project.Workspace,
+			Workflow:  project.Workflow,
+		}
+		if shards > 0 {
+			shard := i % shards
+			entry.Shard = &shard
+		}
+		entries = append(entries, entry)
+	}
+
+	content, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
@@ -0,0 +1,80 @@
+package atlantis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMatrix(t *testing.T) {
+	config := &Config{
+		Projects: []Project{
+			{Name: "a", Dir: "a", Workspace: "default", Workflow: "w"},
+			{Name: "b", Dir: "b", Workspace: "dev"},
+			{Name: "c", Dir: "c", Workspace: "default"},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		config         *Config
+		matrixShards   string
+		expectedOutput string
+		expectedError  bool
+	}{
+		{
+			name:         "NoSharding",
+			config:       config,
+			matrixShards: "0",
+			expectedOutput: `[{"name":"a","dir":"a","workspace":"default","workflow":"w"},` +
+				`{"name":"b","dir":"b","workspace":"dev","workflow":""},` +
+				`{"name":"c","dir":"c","workspace":"default","workflow":""}]` + "\n",
+		},
+		{
+			name:         "TwoShards",
+			config:       config,
+			matrixShards: "2",
+			expectedOutput: `[{"name":"a","dir":"a","workspace":"default","workflow":"w","shard":0},` +
+				`{"name":"b","dir":"b","workspace":"dev","workflow":"","shard":1},` +
+				`{"name":"c","dir":"c","workspace":"default","workflow":"","shard":0}]` + "\n",
+		},
+		{
+			name:           "MoreShardsThanProjects",
+			config:         &Config{Projects: []Project{{Name: "a", Dir: "a", Workspace: "default"}}},
+			matrixShards:   "2",
+			expectedOutput: `[{"name":"a","dir":"a","workspace":"default","workflow":"","shard":0}]` + "\n",
+		},
+		{
+			name:         "OneShard",
+			config:       config,
+			matrixShards: "1",
+			expectedOutput: `[{"name":"a","dir":"a","workspace":"default","workflow":"w","shard":0},` +
+				`{"name":"b","dir":"b","workspace":"dev","workflow":"","shard":0},` +
+				`{"name":"c","dir":"c","workspace":"default","workflow":"","shard":0}]` + "\n",
+		},
+		{
+			name:           "NoProjects",
+			config:         &Config{},
+			matrixShards:   "1",
+			expectedOutput: "[]\n",
+		},
+		{
+			name:          "InvalidShards",
+			config:        config,
+			matrixShards:  "-1",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := renderMatrix(tc.config, tc.matrixShards)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedOutput, string(output))
+			}
+		})
+	}
+}
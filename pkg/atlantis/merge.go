@@ -0,0 +1,137 @@
+package atlantis
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+// managedProjectMarker is the comment added on top of every project written by the generator,
+// so a later merge can tell generated projects apart from hand-written ones.
+const managedProjectMarker = "managed by atlantis-yaml-generator"
+
+const projectsKey = "projects"
+
+// mergeOutputYAML merges the generated config into an existing atlantis.yaml document.
+// Hand-written projects, workflows and any other top-level keys are kept with their comments
+// and key order, while the projects managed by the generator are replaced.
+func mergeOutputYAML(generated *yaml.Node, existingYAML, managedPrefix string) ([]byte, error) {
+	generatedProjects := mappingValue(generated, projectsKey)
+	if generatedProjects != nil {
+		for _, project := range generatedProjects.Content {
+			project.HeadComment = managedProjectMarker
+		}
+	}
+
+	var document yaml.Node
+	err := yaml.Unmarshal([]byte(existingYAML), &document)
+	if err != nil {
+		return nil, fmt.Errorf("parsing existing atlantis.yaml file: %w", err)
+	}
+	// Empty documents are replaced by the generated config
+	if len(document.Content) == 0 {
+		return yaml.Marshal(generated)
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("existing atlantis.yaml file is not a YAML mapping")
+	}
+
+	// Generated top-level keys are updated in place, others are kept as they are
+	var generatedProjectsKey *yaml.Node
+	for i := 0; i < len(generated.Content); i += 2 {
+		key, value := generated.Content[i], generated.Content[i+1]
+		if key.Value == projectsKey {
+			generatedProjectsKey = key
+			continue
+		}
+		setMappingValue(root, key, value)
+	}
+
+	existingProjects := mappingValue(root, projectsKey)
+	if existingProjects == nil || existingProjects.Kind != yaml.SequenceNode {
+		setMappingValue(root, generatedProjectsKey, generatedProjects)
+	} else {
+		existingProjects.Content = mergeProjects(existingProjects.Content, generatedProjects.Content, managedPrefix)
+		// Flow style would render every generated project in a single line
+		existingProjects.Style = 0
+	}
+	err = checkGeneratedAnchors(&document, generated)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&document)
+}
+
+// mergeProjects replaces the managed projects of the existing list by the generated ones.
+// Generated projects are inserted where the first managed project was, or appended.
+func mergeProjects(existingProjects, generatedProjects []*yaml.Node, managedPrefix string) []*yaml.Node {
+	mergedProjects := []*yaml.Node{}
+	insertIndex := -1
+	for _, project := range existingProjects {
+		if isManagedProject(project, managedPrefix) {
+			if insertIndex == -1 {
+				insertIndex = len(mergedProjects)
+			}
+			continue
+		}
+		mergedProjects = append(mergedProjects, project)
+	}
+	if insertIndex == -1 {
+		return append(mergedProjects, generatedProjects...)
+	}
+	return append(mergedProjects[:insertIndex],
+		append(generatedProjects, mergedProjects[insertIndex:]...)...)
+}
+
+func isManagedProject(project *yaml.Node, managedPrefix string) bool {
+	if strings.Contains(project.HeadComment, managedProjectMarker) {
+		return true
+	}
+	if managedPrefix == "" {
+		return false
+	}
+	name := mappingValue(project, "name")
+	return name != nil && strings.HasPrefix(name.Value, managedPrefix)
+}
+
+// mappingValue returns the value node of a mapping key, or nil if not found.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue replaces the value of a mapping key keeping its comments, or appends the key.
+func setMappingValue(mapping, key, value *yaml.Node) {
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value == key.Value {
+			existingValue := mapping.Content[i+1]
+			value.HeadComment = existingValue.HeadComment
+			value.LineComment = existingValue.LineComment
+			value.FootComment = existingValue.FootComment
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, key, value)
+}
+
+// readExistingOutput returns the content of the output file, or nothing if it doesn't exist yet.
+func readExistingOutput(outputFile string) (string, error) {
+	content, err := helpers.ReadFile(outputFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return content, err
+}
@@ -0,0 +1,195 @@
+package atlantis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeOutputYAML(t *testing.T) {
+	config := &Config{
+		Version:       3,
+		Automerge:     true,
+		ParallelApply: true,
+		ParallelPlan:  true,
+		Projects: []Project{
+			{Name: "app", Workspace: "default", Dir: "app", Autoplan: Autoplan{Enabled: true, WhenModified: []string{"*.tf"}}},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		existingYAML  string
+		managedPrefix string
+		expectedYAML  string
+		expectedError bool
+	}{
+		{
+			name:         "EmptyFile",
+			existingYAML: "",
+			expectedYAML: `version: 3
+automerge: true
+parallel_apply: true
+parallel_plan: true
+projects:
+    # managed by atlantis-yaml-generator
+    - name: app
+      workspace: default
+      dir: app
+      autoplan:
+        enabled: true
+        when_modified:
+            - '*.tf'
+`,
+		},
+		{
+			name: "KeepHandWrittenSections",
+			existingYAML: `# Repo level config
+version: 3
+automerge: false # merged by the generator
+delete_source_branch_on_merge: true
+projects:
+    # Manual project
+    - name: manual
+      dir: manual
+    # managed by atlantis-yaml-generator
+    - name: old-app
+      dir: old-app
+    - name: other-manual
+      dir: other
+workflows:
+    custom:
+        plan:
+            steps:
+                - init
+`,
+			expectedYAML: `# Repo level config
+version: 3
+automerge: true # merged by the generator
+delete_source_branch_on_merge: true
+projects:
+    # Manual project
+    - name: manual
+      dir: manual
+    # managed by atlantis-yaml-generator
+    - name: app
+      workspace: default
+      dir: app
+      autoplan:
+        enabled: true
+        when_modified:
+            - '*.tf'
+    - name: other-manual
+      dir: other
+workflows:
+    custom:
+        plan:
+            steps:
+                - init
+parallel_apply: true
+parallel_plan: true
+`,
+		},
+		{
+			name: "ManagedPrefix",
+			existingYAML: `version: 3
+projects:
+    - name: gen-old
+      dir: old
+    - name: manual
+      dir: manual
+`,
+			managedPrefix: "gen-",
+			expectedYAML: `version: 3
+projects:
+    # managed by atlantis-yaml-generator
+    - name: app
+      workspace: default
+      dir: app
+      autoplan:
+        enabled: true
+        when_modified:
+            - '*.tf'
+    - name: manual
+      dir: manual
+automerge: true
+parallel_apply: true
+parallel_plan: true
+`,
+		},
+		{
+			name: "NoProjectsKey",
+			existingYAML: `version: 3
+automerge: true
+parallel_apply: true
+parallel_plan: true
+`,
+			expectedYAML: `version: 3
+automerge: true
+parallel_apply: true
+parallel_plan: true
+projects:
+    # managed by atlantis-yaml-generator
+    - name: app
+      workspace: default
+      dir: app
+      autoplan:
+        enabled: true
+        when_modified:
+            - '*.tf'
+`,
+		},
+		{
+			name:          "NotAMapping",
+			existingYAML:  "- item\n",
+			expectedError: true,
+		},
+		{
+			name:          "InvalidYAML",
+			existingYAML:  "version: [3\n",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			configNode, err := generateConfigNode(config, "")
+			assert.NoError(t, err)
+			mergedYAML, err := mergeOutputYAML(configNode, tc.existingYAML, tc.managedPrefix)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedYAML, string(mergedYAML))
+			}
+		})
+	}
+}
+
+func TestRenderOutputYAMLMerge(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "atlantis.yaml")
+	config := &Config{
+		Version:  3,
+		Projects: []Project{{Name: "app", Workspace: "default", Dir: "app"}},
+	}
+	// The first merge creates the file, the second one must be idempotent
+	firstYAML, err := renderOutputYAML(config, outputFile, "true", "", "", "false")
+	assert.NoError(t, err)
+	err = writeOutput(firstYAML, outputFile, "file", "0644")
+	assert.NoError(t, err)
+
+	secondYAML, err := renderOutputYAML(config, outputFile, "true", "", "", "false")
+	assert.NoError(t, err)
+	assert.Equal(t, string(firstYAML), string(secondYAML))
+
+	// The generated-file header of the existing file is not kept as a comment
+	err = writeOutput(addOutputHeader(firstYAML, "1.2.3", nil), outputFile, "file", "0644")
+	assert.NoError(t, err)
+	headerYAML, err := renderOutputYAML(config, outputFile, "true", "", "", "false")
+	assert.NoError(t, err)
+	assert.Equal(t, string(firstYAML), string(headerYAML))
+
+	_, err = renderOutputYAML(config, outputFile, "maybe", "", "", "false")
+	assert.Error(t, err)
+}
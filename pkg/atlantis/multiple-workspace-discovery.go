@@ -56,6 +56,17 @@ func multiWorkspaceDetectProjectWorkspaces(changedFiles []string, enablePRFilter
 	return foldersList, nil
 }
 
+// multiWorkspaceGenWhenModified scopes the when_modified list to a single workspace:
+// changes outside the pattern detector folder are shared by all workspaces,
+// while inside it only the workspace var file triggers an autoplan.
+// Atlantis evaluates when_modified patterns in order, so the last matching pattern wins.
+func multiWorkspaceGenWhenModified(whenModified []string, patternDetector, workspace string) []string {
+	scopedWhenModified := append([]string{}, whenModified...)
+	return append(scopedWhenModified,
+		fmt.Sprintf("!%s/**", patternDetector),
+		fmt.Sprintf("%s/**/%s%s", patternDetector, workspace, tfvarsExtension))
+}
+
 func multiWorkspaceDiscoveryFilter(info os.FileInfo, path, patternDetector string) bool {
 	return info.IsDir() &&
 		info.Name() == patternDetector &&
@@ -28,3 +28,12 @@ func TestMultiWorkspaceGenWorkspaceList(t *testing.T) {
 	assert.Equal(t, []string{"test1"}, workspaceList)
 
 }
+
+func TestMultiWorkspaceGenWhenModified(t *testing.T) {
+	whenModified := []string{"**/*.tf", "**/*.tfvars"}
+
+	scopedWhenModified := multiWorkspaceGenWhenModified(whenModified, "workspace_vars", "staging")
+	assert.Equal(t, []string{"**/*.tf", "**/*.tfvars", "!workspace_vars/**", "workspace_vars/**/staging.tfvars"}, scopedWhenModified)
+	// The global list must not be modified
+	assert.Equal(t, []string{"**/*.tf", "**/*.tfvars"}, whenModified)
+}
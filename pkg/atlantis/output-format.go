@@ -0,0 +1,69 @@
+package atlantis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+var outputTemplateFuncs = template.FuncMap{
+	"join":  func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"quote": func(s string) string { return fmt.Sprintf("%q", s) },
+	"toJson": func(v interface{}) (string, error) {
+		content, err := json.Marshal(v)
+		return string(content), err
+	},
+}
+
+// renderOutputFormat converts the rendered atlantis.yaml content into the requested output format.
+func renderOutputFormat(yamlContent []byte, config *Config, outputFormat, outputTemplate, matrixShards string) ([]byte, error) {
+	switch outputFormat {
+	case "yaml":
+		return yamlContent, nil
+	case "json":
+		return yamlToJSON(yamlContent)
+	case "template":
+		return renderOutputTemplate(config, outputTemplate)
+	case "matrix":
+		return renderMatrix(config, matrixShards)
+	default:
+		return nil, fmt.Errorf("output format '%s' is not supported", outputFormat)
+	}
+}
+
+func yamlToJSON(yamlContent []byte) ([]byte, error) {
+	var document interface{}
+	err := yaml.Unmarshal(yamlContent, &document)
+	if err != nil {
+		return nil, err
+	}
+	jsonContent, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(jsonContent, '\n'), nil
+}
+
+// renderOutputTemplate renders a Go text/template against the generated config,
+// i.e. {{ range .Projects }}{{ .Name }} {{ .Dir }}{{ "\n" }}{{ end }}
+func renderOutputTemplate(config *Config, outputTemplate string) ([]byte, error) {
+	if outputTemplate == "" {
+		return nil, fmt.Errorf("template output format requires the template parameter")
+	}
+	tmpl, err := template.New("output").
+		Funcs(outputTemplateFuncs).
+		Option("missingkey=error").
+		Parse(outputTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output template: %w", err)
+	}
+	var output strings.Builder
+	err = tmpl.Execute(&output, config)
+	if err != nil {
+		return nil, fmt.Errorf("rendering output template: %w", err)
+	}
+	return []byte(output.String()), nil
+}
@@ -0,0 +1,121 @@
+package atlantis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderOutputFormat(t *testing.T) {
+	config := &Config{
+		Version: 3,
+		Projects: []Project{
+			{Name: "app-dev", Workspace: "dev", Dir: "app", Workflow: "custom", Autoplan: Autoplan{Enabled: true, WhenModified: []string{"*.tf"}}},
+			{Name: "db", Workspace: "default", Dir: "db", Autoplan: Autoplan{Enabled: false, WhenModified: []string{"*.tf"}}},
+		},
+	}
+	yamlContent, err := renderOutputYAML(config, "", "false", "", "", "false")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		outputFormat   string
+		outputTemplate string
+		expectedOutput string
+		expectedError  bool
+	}{
+		{
+			name:           "YAML",
+			outputFormat:   "yaml",
+			expectedOutput: string(yamlContent),
+		},
+		{
+			name:         "JSON",
+			outputFormat: "json",
+			expectedOutput: `{
+  "automerge": false,
+  "parallel_apply": false,
+  "parallel_plan": false,
+  "projects": [
+    {
+      "autoplan": {
+        "enabled": true,
+        "when_modified": [
+          "*.tf"
+        ]
+      },
+      "dir": "app",
+      "name": "app-dev",
+      "workflow": "custom",
+      "workspace": "dev"
+    },
+    {
+      "autoplan": {
+        "enabled": false,
+        "when_modified": [
+          "*.tf"
+        ]
+      },
+      "dir": "db",
+      "name": "db",
+      "workspace": "default"
+    }
+  ],
+  "version": 3
+}
+`,
+		},
+		{
+			name:           "Template",
+			outputFormat:   "template",
+			outputTemplate: `{{ range .Projects }}{{ .Name }},{{ .Dir }},{{ .Workspace }},{{ .Autoplan.Enabled }},{{ join ";" .Autoplan.WhenModified }}{{ "\n" }}{{ end }}`,
+			expectedOutput: "app-dev,app,dev,true,*.tf\ndb,db,default,false,*.tf\n",
+		},
+		{
+			name:           "TemplateToJson",
+			outputFormat:   "template",
+			outputTemplate: `{{ range .Projects }}{{ toJson .Name }} {{ quote .Dir }} {{ end }}`,
+			expectedOutput: `"app-dev" "app" "db" "db" `,
+		},
+		{
+			name:          "MissingTemplate",
+			outputFormat:  "template",
+			expectedError: true,
+		},
+		{
+			name:           "InvalidTemplate",
+			outputFormat:   "template",
+			outputTemplate: "{{ range .Projects }",
+			expectedError:  true,
+		},
+		{
+			name:           "TemplateExecutionError",
+			outputFormat:   "template",
+			outputTemplate: "{{ .Unknown }}",
+			expectedError:  true,
+		},
+		{
+			name:         "Matrix",
+			outputFormat: "matrix",
+			expectedOutput: `[{"name":"app-dev","dir":"app","workspace":"dev","workflow":"custom"},` +
+				`{"name":"db","dir":"db","workspace":"default","workflow":""}]` + "\n",
+		},
+		{
+			name:          "UnsupportedFormat",
+			outputFormat:  "toml",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := renderOutputFormat(yamlContent, config, tc.outputFormat, tc.outputTemplate, "0")
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedOutput, string(output))
+			}
+		})
+	}
+}
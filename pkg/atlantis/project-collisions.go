@@ -0,0 +1,107 @@
+package atlantis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveProjectCollisions checks that project names and dir+workspace pairs are unique,
+// since Atlantis rejects the whole config file on duplicates.
+// Duplicated names are either reported or disambiguated depending on the strategy.
+func resolveProjectCollisions(strategy string, projects []Project) ([]Project, error) {
+	// Two projects for the same dir and workspace can't be disambiguated by renaming them
+	if report := collisionReport(projects, projectDirWorkspace); report != "" {
+		return projects, fmt.Errorf("duplicated project dir and workspace pairs found:\n%s", report)
+	}
+	switch strategy {
+	case "fail":
+	case "hash-suffix":
+		projects = disambiguateProjectNames(projects, func(project Project, _ int) string {
+			return fmt.Sprintf("%s-%s", project.Name, shortHash(projectDirWorkspace(project)))
+		})
+	case "index-suffix":
+		projects = disambiguateProjectNames(projects, func(project Project, index int) string {
+			return fmt.Sprintf("%s-%d", project.Name, index+1)
+		})
+	default:
+		return projects, fmt.Errorf("name collision strategy '%s' is not supported", strategy)
+	}
+	// Disambiguated names may still collide with other project names
+	if report := collisionReport(projects, projectName); report != "" {
+		return projects, fmt.Errorf("duplicated project names found:\n%s", report)
+	}
+	return projects, nil
+}
+
+// applyResolvedProjectNames renames the projects like their dir and workspace pair
+// in the resolved projects, so names don't depend on which projects were filtered out.
+func applyResolvedProjectNames(projects, resolvedProjects []Project) []Project {
+	resolvedNames := make(map[string]string, len(resolvedProjects))
+	for _, project := range resolvedProjects {
+		resolvedNames[projectDirWorkspace(project)] = project.Name
+	}
+	for i := range projects {
+		if name, ok := resolvedNames[projectDirWorkspace(projects[i])]; ok {
+			projects[i].Name = name
+		}
+	}
+	return projects
+}
+
+// disambiguateProjectNames renames every project sharing its name with another project.
+func disambiguateProjectNames(projects []Project, rename func(project Project, index int) string) []Project {
+	groups := groupProjects(projects, projectName)
+	renamedProjects := make([]Project, len(projects))
+	copy(renamedProjects, projects)
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		for index, projectIndex := range group {
+			renamedProjects[projectIndex].Name = rename(projects[projectIndex], index)
+		}
+	}
+	return renamedProjects
+}
+
+// collisionReport returns a human readable list of the projects sharing the same key.
+func collisionReport(projects []Project, key func(Project) string) string {
+	var report strings.Builder
+	for _, group := range groupProjects(projects, key) {
+		if len(group) < 2 {
+			continue
+		}
+		var entries []string
+		for _, projectIndex := range group {
+			project := projects[projectIndex]
+			entries = append(entries, fmt.Sprintf("name '%s' dir '%s' workspace '%s'",
+				project.Name, project.Dir, project.Workspace))
+		}
+		report.WriteString(fmt.Sprintf("  - %s: %s\n", key(projects[group[0]]), strings.Join(entries, ", ")))
+	}
+	return report.String()
+}
+
+// groupProjects returns the indexes of the projects grouped by key, in order of appearance.
+func groupProjects(projects []Project, key func(Project) string) [][]int {
+	var groups [][]int
+	groupIndex := make(map[string]int)
+	for i, project := range projects {
+		k := key(project)
+		if index, ok := groupIndex[k]; ok {
+			groups[index] = append(groups[index], i)
+			continue
+		}
+		groupIndex[k] = len(groups)
+		groups = append(groups, []int{i})
+	}
+	return groups
+}
+
+func projectName(project Project) string {
+	return project.Name
+}
+
+func projectDirWorkspace(project Project) string {
+	return fmt.Sprintf("%s@%s", project.Dir, project.Workspace)
+}
@@ -0,0 +1,178 @@
+package atlantis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+func TestResolveProjectCollisions(t *testing.T) {
+	collidingProjects := []Project{
+		{Name: "team-a-vpc", Dir: "team-a/vpc", Workspace: "default"},
+		{Name: "team-a-vpc", Dir: "team/a-vpc", Workspace: "default"},
+		{Name: "app-prod", Dir: "app", Workspace: "prod"},
+	}
+
+	testCases := []struct {
+		name             string
+		strategy         string
+		projects         []Project
+		expectedProjects []Project
+		expectedError    bool
+	}{
+		{
+			name:     "NoCollisions",
+			strategy: "fail",
+			projects: []Project{
+				{Name: "app", Dir: "app", Workspace: "default"},
+				{Name: "app-prod", Dir: "app", Workspace: "prod"},
+			},
+			expectedProjects: []Project{
+				{Name: "app", Dir: "app", Workspace: "default"},
+				{Name: "app-prod", Dir: "app", Workspace: "prod"},
+			},
+		},
+		{
+			name:          "FailOnDuplicatedNames",
+			strategy:      "fail",
+			projects:      collidingProjects,
+			expectedError: true,
+		},
+		{
+			name:     "IndexSuffix",
+			strategy: "index-suffix",
+			projects: collidingProjects,
+			expectedProjects: []Project{
+				{Name: "team-a-vpc-1", Dir: "team-a/vpc", Workspace: "default"},
+				{Name: "team-a-vpc-2", Dir: "team/a-vpc", Workspace: "default"},
+				{Name: "app-prod", Dir: "app", Workspace: "prod"},
+			},
+		},
+		{
+			name:     "HashSuffix",
+			strategy: "hash-suffix",
+			projects: collidingProjects,
+			expectedProjects: []Project{
+				{Name: "team-a-vpc-" + shortHash("team-a/vpc@default"), Dir: "team-a/vpc", Workspace: "default"},
+				{Name: "team-a-vpc-" + shortHash("team/a-vpc@default"), Dir: "team/a-vpc", Workspace: "default"},
+				{Name: "app-prod", Dir: "app", Workspace: "prod"},
+			},
+		},
+		{
+			name:     "DisambiguatedNameStillCollides",
+			strategy: "index-suffix",
+			projects: []Project{
+				{Name: "app", Dir: "app", Workspace: "default"},
+				{Name: "app", Dir: "other/app", Workspace: "default"},
+				{Name: "app-1", Dir: "app-1", Workspace: "default"},
+			},
+			expectedError: true,
+		},
+		{
+			name:     "DuplicatedDirWorkspace",
+			strategy: "index-suffix",
+			projects: []Project{
+				{Name: "app", Dir: "app", Workspace: "default"},
+				{Name: "app-copy", Dir: "app", Workspace: "default"},
+			},
+			expectedError: true,
+		},
+		{
+			name:          "UnsupportedStrategy",
+			strategy:      "ignore",
+			projects:      collidingProjects,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			projects, err := resolveProjectCollisions(tc.strategy, tc.projects)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedProjects, projects)
+			}
+		})
+	}
+}
+
+func TestCollisionReport(t *testing.T) {
+	projects := []Project{
+		{Name: "team-a-vpc", Dir: "team-a/vpc", Workspace: "default"},
+		{Name: "team-a-vpc", Dir: "team/a-vpc", Workspace: "default"},
+		{Name: "app", Dir: "app", Workspace: "default"},
+	}
+	expectedReport := "  - team-a-vpc: name 'team-a-vpc' dir 'team-a/vpc' workspace 'default', " +
+		"name 'team-a-vpc' dir 'team/a-vpc' workspace 'default'\n"
+
+	assert.Equal(t, expectedReport, collisionReport(projects, projectName))
+	assert.Equal(t, "", collisionReport(projects, projectDirWorkspace))
+}
+
+func TestApplyResolvedProjectNames(t *testing.T) {
+	resolvedProjects := []Project{
+		{Name: "team-a-vpc-1", Dir: "team-a/vpc", Workspace: "default"},
+		{Name: "team-a-vpc-2", Dir: "team/a-vpc", Workspace: "default"},
+	}
+	projects := []Project{
+		{Name: "team-a-vpc", Dir: "team/a-vpc", Workspace: "default"},
+		{Name: "app", Dir: "app", Workspace: "default"},
+	}
+	assert.Equal(t, []Project{
+		{Name: "team-a-vpc-2", Dir: "team/a-vpc", Workspace: "default"},
+		{Name: "app", Dir: "app", Workspace: "default"},
+	}, applyResolvedProjectNames(projects, resolvedProjects))
+}
+
+func TestGenerateAtlantisYAMLStableNames(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, dir := range []string{"team-a/vpc", "team/a-vpc"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(baseDir, dir), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(baseDir, dir, "main.tf"), []byte(""), 0644))
+	}
+	outputFile := filepath.Join(t.TempDir(), "atlantis.yaml")
+	config.GlobalConfig.Parameters = map[string]string{
+		"terraform-base-dir":             baseDir,
+		"discovery-mode":                 "single-workspace",
+		"pattern-detector":               "main.tf",
+		"name-collision-strategy":        "index-suffix",
+		"pr-filter":                      "true",
+		"output-file":                    outputFile,
+		"output-type":                    "file",
+		"output-file-mode":               "0644",
+		"output-format":                  "yaml",
+		"automerge":                      "false",
+		"parallel-apply":                 "false",
+		"parallel-plan":                  "false",
+		"workspace-scoped-when-modified": "true",
+		"merge":                          "false",
+		"validate":                       "false",
+		"check":                          "false",
+		"header":                         "false",
+		"yaml-anchors":                   "false",
+		"force":                          "false",
+	}
+
+	// The suffix doesn't depend on which colliding projects the PR touches
+	err := GenerateAtlantisYAML(scm.ProviderFunc(func() ([]scm.ChangedFile, error) {
+		return []scm.ChangedFile{{Path: "team-a/vpc/main.tf", Status: scm.StatusModified}}, nil
+	}))
+	assert.NoError(t, err)
+	content, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "name: team-a-vpc-2\n")
+	assert.NotContains(t, string(content), "team-a-vpc-1")
+
+	// Collisions with projects filtered out are detected too
+	config.GlobalConfig.Parameters["name-collision-strategy"] = "fail"
+	err = GenerateAtlantisYAML(scm.ProviderFunc(func() ([]scm.ChangedFile, error) {
+		return []scm.ChangedFile{{Path: "team-a/vpc/main.tf", Status: scm.StatusModified}}, nil
+	}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package atlantis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+)
+
+const shortHashLength = 8
+
+// projectNameData is the data available to the project name template.
+type projectNameData struct {
+	Dir       string
+	Workspace string
+	// Name is the name generated by the default naming convention
+	Name string
+}
+
+var projectNameFuncs = template.FuncMap{
+	"replace":         func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"trimPrefix":      func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix":      func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"lower":           strings.ToLower,
+	"upper":           strings.ToUpper,
+	"join":            func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"segments":        pathSegments,
+	"segment":         pathSegment,
+	"base":            path.Base,
+	"parent":          path.Dir,
+	"workspaceSuffix": workspaceSuffix,
+	"shortHash":       shortHash,
+}
+
+func parseProjectNameTemplate(nameTemplate string) (*template.Template, error) {
+	// An empty template keeps the default naming convention
+	if nameTemplate == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("project-name").
+		Funcs(projectNameFuncs).
+		Option("missingkey=error").
+		Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project name template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func renderProjectName(tmpl *template.Template, dir, workspace string) (string, error) {
+	defaultName := genProjectName(dir, workspace)
+	if tmpl == nil {
+		return defaultName, nil
+	}
+	var name strings.Builder
+	err := tmpl.Execute(&name, projectNameData{
+		Dir:       dir,
+		Workspace: workspace,
+		Name:      defaultName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("rendering project name for dir '%s' and workspace '%s': %w", dir, workspace, err)
+	}
+	if strings.TrimSpace(name.String()) == "" {
+		return "", fmt.Errorf("project name template rendered an empty name for dir '%s' and workspace '%s'", dir, workspace)
+	}
+	return name.String(), nil
+}
+
+// pathSegments splits a slash separated path into its non empty segments.
+func pathSegments(p string) []string {
+	segments := []string{}
+	for _, segment := range strings.Split(p, "/") {
+		if segment != "" && segment != "." {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// pathSegment returns the segment at index i, negative indexes count from the end.
+func pathSegment(i int, p string) string {
+	segments := pathSegments(p)
+	if i < 0 {
+		i += len(segments)
+	}
+	if i < 0 || i >= len(segments) {
+		return ""
+	}
+	return segments[i]
+}
+
+// workspaceSuffix returns the workspace prefixed by sep, or nothing for the default workspace.
+func workspaceSuffix(sep, workspace string) string {
+	if workspace == "default" || workspace == "" {
+		return ""
+	}
+	return sep + workspace
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:shortHashLength]
+}
@@ -0,0 +1,105 @@
+package atlantis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderProjectName(t *testing.T) {
+	testCases := []struct {
+		name          string
+		template      string
+		dir           string
+		workspace     string
+		expectedName  string
+		expectedError bool
+	}{
+		{
+			name:         "NoTemplate",
+			template:     "",
+			dir:          "team/app",
+			workspace:    "prod",
+			expectedName: "team-app-prod",
+		},
+		{
+			name:         "ReplaceSeparator",
+			template:     `{{ .Dir | replace "/" "_" }}__{{ .Workspace }}`,
+			dir:          "team/app",
+			workspace:    "prod",
+			expectedName: "team_app__prod",
+		},
+		{
+			name:         "SegmentsAndBase",
+			template:     `{{ .Dir | segment 0 }}.{{ .Dir | base }}{{ workspaceSuffix "@" .Workspace }}`,
+			dir:          "team/stacks/app",
+			workspace:    "default",
+			expectedName: "team.app",
+		},
+		{
+			name:         "NegativeSegment",
+			template:     `{{ .Dir | segment -2 }}-{{ .Dir | segments | len }}`,
+			dir:          "team/stacks/app",
+			workspace:    "default",
+			expectedName: "stacks-3",
+		},
+		{
+			name:         "ShortHash",
+			template:     `{{ .Dir | base }}-{{ .Dir | shortHash }}`,
+			dir:          "team/app",
+			workspace:    "default",
+			expectedName: "app-" + shortHash("team/app"),
+		},
+		{
+			name:         "DefaultName",
+			template:     `{{ .Name | upper }}`,
+			dir:          "team/app",
+			workspace:    "dev",
+			expectedName: "TEAM-APP-DEV",
+		},
+		{
+			name:          "EmptyName",
+			template:      `{{ workspaceSuffix "-" .Workspace }}`,
+			dir:           "team/app",
+			workspace:     "default",
+			expectedError: true,
+		},
+		{
+			name:          "UnknownField",
+			template:      `{{ .Path }}`,
+			dir:           "team/app",
+			workspace:     "default",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := parseProjectNameTemplate(tc.template)
+			assert.NoError(t, err)
+			name, err := renderProjectName(tmpl, tc.dir, tc.workspace)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedName, name)
+			}
+		})
+	}
+}
+
+func TestParseProjectNameTemplate(t *testing.T) {
+	_, err := parseProjectNameTemplate("{{ .Dir ")
+	assert.Error(t, err)
+
+	_, err = parseProjectNameTemplate("{{ .Dir | unknownFunc }}")
+	assert.Error(t, err)
+}
+
+func TestPathSegment(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, pathSegments("./a//b/c/"))
+	assert.Equal(t, "a", pathSegment(0, "a/b/c"))
+	assert.Equal(t, "c", pathSegment(-1, "a/b/c"))
+	assert.Equal(t, "", pathSegment(3, "a/b/c"))
+	assert.Equal(t, "", pathSegment(-4, "a/b/c"))
+}
@@ -0,0 +1,123 @@
+package atlantis
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+)
+
+// projectRule maps a glob or regex pattern on a project field to a value.
+// Rules are written as "<field>:<glob>=<value>" or "<field>~<regex>=<value>",
+// separated by ";", and are evaluated in order (first match wins).
+type projectRule struct {
+	Field   string
+	Pattern *regexp.Regexp
+	Value   string
+}
+
+const projectRuleSeparator = ";"
+
+var projectRuleFields = []string{"dir", "name", "workspace"}
+
+func parseProjectRules(rules string) (parsedRules []projectRule, err error) {
+	for _, rule := range strings.Split(rules, projectRuleSeparator) {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		parsedRule, err := parseProjectRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		parsedRules = append(parsedRules, parsedRule)
+	}
+	return parsedRules, nil
+}
+
+func parseProjectRule(rule string) (projectRule, error) {
+	// The value is everything after the last '=', so regex patterns may contain '='
+	valueIndex := strings.LastIndex(rule, "=")
+	operatorIndex := strings.IndexAny(rule, ":~")
+	if valueIndex == -1 || operatorIndex == -1 || operatorIndex > valueIndex {
+		return projectRule{}, fmt.Errorf("invalid rule '%s', expected <field>:<glob>=<value> or <field>~<regex>=<value>", rule)
+	}
+	field := strings.TrimSpace(rule[:operatorIndex])
+	if !isProjectRuleField(field) {
+		return projectRule{}, fmt.Errorf("invalid rule '%s', field '%s' is not one of [%s]",
+			rule, field, strings.Join(projectRuleFields, "|"))
+	}
+	pattern := rule[operatorIndex+1 : valueIndex]
+	var compiledPattern *regexp.Regexp
+	var err error
+	if rule[operatorIndex] == '~' {
+		compiledPattern, err = regexp.Compile(pattern)
+	} else {
+		compiledPattern, err = helpers.GlobToRegexp(pattern)
+	}
+	if err != nil {
+		return projectRule{}, fmt.Errorf("invalid rule '%s': %w", rule, err)
+	}
+	return projectRule{
+		Field:   field,
+		Pattern: compiledPattern,
+		Value:   strings.TrimSpace(rule[valueIndex+1:]),
+	}, nil
+}
+
+func isProjectRuleField(field string) bool {
+	for _, f := range projectRuleFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func matchProjectRules(rules []projectRule, project Project) (value string, matched bool) {
+	// Return the value of the first rule matching the project
+	for _, rule := range rules {
+		if rule.Pattern.MatchString(projectRuleFieldValue(project, rule.Field)) {
+			return rule.Value, true
+		}
+	}
+	return "", false
+}
+
+func projectRuleFieldValue(project Project, field string) string {
+	switch field {
+	case "dir":
+		return project.Dir
+	case "name":
+		return project.Name
+	case "workspace":
+		return project.Workspace
+	}
+	return ""
+}
+
+func parseAutoplanRules(rules string) ([]projectRule, error) {
+	parsedRules, err := parseProjectRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	// Autoplan rules values must be booleans
+	for _, rule := range parsedRules {
+		if _, err := strconv.ParseBool(rule.Value); err != nil {
+			return nil, fmt.Errorf("invalid autoplan rule value '%s', expected true or false", rule.Value)
+		}
+	}
+	return parsedRules, nil
+}
+
+func autoplanEnabled(rules []projectRule, project Project) bool {
+	// Autoplan is enabled unless a rule says otherwise
+	value, matched := matchProjectRules(rules, project)
+	if !matched {
+		return true
+	}
+	enabled, _ := strconv.ParseBool(value)
+	return enabled
+}
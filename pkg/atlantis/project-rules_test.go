@@ -0,0 +1,116 @@
+package atlantis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProjectRules(t *testing.T) {
+	testCases := []struct {
+		name          string
+		rules         string
+		expectedCount int
+		expectedError bool
+	}{
+		{
+			name:          "EmptyRules",
+			rules:         "",
+			expectedCount: 0,
+		},
+		{
+			name:          "GlobAndRegexRules",
+			rules:         "dir:k8s/**=helm; name~^security-.*=policy-check;",
+			expectedCount: 2,
+		},
+		{
+			name:          "RegexWithEqualSign",
+			rules:         "name~^a=b$=workflow1",
+			expectedCount: 1,
+		},
+		{
+			name:          "MissingValue",
+			rules:         "dir:k8s/**",
+			expectedError: true,
+		},
+		{
+			name:          "UnknownField",
+			rules:         "path:k8s/**=helm",
+			expectedError: true,
+		},
+		{
+			name:          "InvalidRegex",
+			rules:         "dir~[a-z=helm",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := parseProjectRules(tc.rules)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, rules, tc.expectedCount)
+			}
+		})
+	}
+}
+
+func TestMatchProjectRules(t *testing.T) {
+	rules, err := parseProjectRules("dir:k8s/*=helm;dir:security/**=policy-check;workspace~^prod=prod")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name            string
+		project         Project
+		expectedValue   string
+		expectedMatched bool
+	}{
+		{
+			name:            "GlobMatch",
+			project:         Project{Dir: "k8s/cluster", Workspace: "prod"},
+			expectedValue:   "helm",
+			expectedMatched: true,
+		},
+		{
+			name:            "NestedGlobMatch",
+			project:         Project{Dir: "security/iam/roles", Workspace: "default"},
+			expectedValue:   "policy-check",
+			expectedMatched: true,
+		},
+		{
+			name:            "RegexMatch",
+			project:         Project{Dir: "k8s/cluster/nested", Workspace: "production"},
+			expectedValue:   "prod",
+			expectedMatched: true,
+		},
+		{
+			name:            "NoMatch",
+			project:         Project{Dir: "network/vpc", Workspace: "default"},
+			expectedValue:   "",
+			expectedMatched: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, matched := matchProjectRules(rules, tc.project)
+			assert.Equal(t, tc.expectedValue, value)
+			assert.Equal(t, tc.expectedMatched, matched)
+		})
+	}
+}
+
+func TestAutoplanEnabled(t *testing.T) {
+	rules, err := parseAutoplanRules("name:*-production=false;dir:legacy/**=false;dir:legacy/**=true")
+	assert.NoError(t, err)
+
+	assert.True(t, autoplanEnabled(rules, Project{Name: "app-staging", Dir: "app"}))
+	assert.False(t, autoplanEnabled(rules, Project{Name: "app-production", Dir: "app"}))
+	assert.False(t, autoplanEnabled(rules, Project{Name: "legacy-app", Dir: "legacy/app"}))
+
+	_, err = parseAutoplanRules("name:*-production=off")
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package atlantis
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/totmicro/atlantis-yaml-generator/pkg/gitdiff"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+// removedPatternDetectorPaths returns the paths that no longer exist after the PR,
+// that is removed files and the previous path of renamed ones.
+func removedPatternDetectorPaths(changedFiles []scm.ChangedFile) (paths []string) {
+	for _, file := range changedFiles {
+		switch file.Status {
+		case scm.StatusRemoved:
+			paths = append(paths, file.Path)
+		case scm.StatusRenamed:
+			paths = append(paths, file.PreviousPath)
+		}
+	}
+	return paths
+}
+
+// removedProjectScope returns the project folder and workspace a removed file
+// was detecting on the base ref, if any.
+func removedProjectScope(filePath, discoveryMode, patternDetector string) (folder, workspace string, found bool) {
+	if filePath == "" || strings.Contains(filePath, ".terraform") {
+		return "", "", false
+	}
+	switch discoveryMode {
+	case "single-workspace":
+		if path.Base(filePath) == patternDetector && path.Dir(filePath) != "." {
+			return path.Dir(filePath), "default", true
+		}
+	case "multi-workspace":
+		folder, varFile, found := strings.Cut(filePath, "/"+patternDetector+"/")
+		if found && strings.HasSuffix(varFile, tfvarsExtension) {
+			return folder, helpers.TrimFileExtension(path.Base(varFile)), true
+		}
+	}
+	return "", "", false
+}
+
+// mergeBaseRemovedPaths returns the removed pattern detector paths confirmed as files on the
+// merge-base of the pull request, so only folders which really were stacks get a project.
+// Without the merge-base, removed projects are left out with a warning.
+func mergeBaseRemovedPaths(changedFiles []scm.ChangedFile, discoveryMode, patternDetector string) map[string]bool {
+	var candidatePaths []string
+	for _, filePath := range removedPatternDetectorPaths(changedFiles) {
+		if _, _, found := removedProjectScope(filePath, discoveryMode, patternDetector); found {
+			candidatePaths = append(candidatePaths, filePath)
+		}
+	}
+	if len(candidatePaths) == 0 {
+		return nil
+	}
+	mergeBasePaths, err := gitdiff.GetMergeBaseFiles(candidatePaths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: the stacks removed by the pull request can't be confirmed on its merge-base, "+
+			"no project is generated for them: %v\n", err)
+		return nil
+	}
+	if len(mergeBasePaths) > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: the pull request removes stacks, planning their destroy needs the code of the base ref, "+
+			"which is gone from the pull request head.\n")
+	}
+	return mergeBasePaths
+}
+
+// addRemovedProjectFolders adds the projects a PR removes or moves away. Their pattern
+// detector files are gone from the checkout so the scan misses them, but they existed on
+// the base ref and Atlantis still needs a project to plan their destroy.
+// Only the paths confirmed on the merge-base are considered.
+// Folders the scan didn't find are returned as removed dirs.
+func addRemovedProjectFolders(foldersList []ProjectFolder, changedFiles []scm.ChangedFile, mergeBasePaths map[string]bool,
+	discoveryMode, patternDetector string) (updatedFoldersList []ProjectFolder, removedDirs []string) {
+
+	folderIndex := map[string]int{}
+	for i, folder := range foldersList {
+		folderIndex[folder.Path] = i
+	}
+	for _, filePath := range removedPatternDetectorPaths(changedFiles) {
+		folder, workspace, found := removedProjectScope(filePath, discoveryMode, patternDetector)
+		if !found || !mergeBasePaths[filePath] {
+			continue
+		}
+		i, exists := folderIndex[folder]
+		if !exists {
+			i = len(foldersList)
+			folderIndex[folder] = i
+			foldersList = append(foldersList, ProjectFolder{Path: folder})
+			removedDirs = append(removedDirs, folder)
+		}
+		if !helpers.IsStringInList(workspace, foldersList[i].WorkspaceList) {
+			foldersList[i].WorkspaceList = append(foldersList[i].WorkspaceList, workspace)
+		}
+	}
+	return foldersList, removedDirs
+}
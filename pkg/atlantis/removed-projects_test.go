@@ -0,0 +1,174 @@
+package atlantis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+func TestRemovedProjectScope(t *testing.T) {
+	testCases := []struct {
+		name              string
+		filePath          string
+		discoveryMode     string
+		expectedFolder    string
+		expectedWorkspace string
+		expectedFound     bool
+	}{
+		{"SingleWorkspace", "stacks/db/main.tf", "single-workspace", "stacks/db", "default", true},
+		{"SingleWorkspaceOtherFile", "stacks/db/vars.tf", "single-workspace", "", "", false},
+		{"SingleWorkspaceRoot", "main.tf", "single-workspace", "", "", false},
+		{"MultiWorkspace", "db/workspace_vars/prod.tfvars", "multi-workspace", "db", "prod", true},
+		{"MultiWorkspaceOtherFile", "db/main.tf", "multi-workspace", "", "", false},
+		{"TerraformFolder", "db/.terraform/modules/main.tf", "single-workspace", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patternDetector := "main.tf"
+			if tc.discoveryMode == "multi-workspace" {
+				patternDetector = "workspace_vars"
+			}
+			folder, workspace, found := removedProjectScope(tc.filePath, tc.discoveryMode, patternDetector)
+			assert.Equal(t, tc.expectedFolder, folder)
+			assert.Equal(t, tc.expectedWorkspace, workspace)
+			assert.Equal(t, tc.expectedFound, found)
+		})
+	}
+}
+
+func TestAddRemovedProjectFolders(t *testing.T) {
+	changedFiles := []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+		{Path: "network/vars.tf", Status: scm.StatusRemoved},
+		{Path: "new/main.tf", Status: scm.StatusRenamed, PreviousPath: "old/main.tf"},
+	}
+	foldersList := []ProjectFolder{
+		{Path: "app", WorkspaceList: []string{"default"}},
+		{Path: "new", WorkspaceList: []string{"default"}},
+	}
+	mergeBasePaths := map[string]bool{"network/main.tf": true, "old/main.tf": true}
+	updatedFoldersList, removedDirs := addRemovedProjectFolders(foldersList, changedFiles, mergeBasePaths, "single-workspace", "main.tf")
+	assert.Equal(t, []ProjectFolder{
+		{Path: "app", WorkspaceList: []string{"default"}},
+		{Path: "new", WorkspaceList: []string{"default"}},
+		{Path: "network", WorkspaceList: []string{"default"}},
+		{Path: "old", WorkspaceList: []string{"default"}},
+	}, updatedFoldersList)
+	assert.Equal(t, []string{"network", "old"}, removedDirs)
+
+	// Removed workspaces are added to the existing folders
+	changedFiles = []scm.ChangedFile{
+		{Path: "db/workspace_vars/prod.tfvars", Status: scm.StatusRemoved},
+		{Path: "db/workspace_vars/dev.tfvars", Status: scm.StatusModified},
+		{Path: "cache/workspace_vars/prod.tfvars", Status: scm.StatusRemoved},
+		{Path: "cache/workspace_vars/dev.tfvars", Status: scm.StatusRemoved},
+	}
+	foldersList = []ProjectFolder{{Path: "db", WorkspaceList: []string{"dev"}}}
+	mergeBasePaths = map[string]bool{
+		"db/workspace_vars/prod.tfvars":    true,
+		"cache/workspace_vars/prod.tfvars": true,
+		"cache/workspace_vars/dev.tfvars":  true,
+	}
+	updatedFoldersList, removedDirs = addRemovedProjectFolders(foldersList, changedFiles, mergeBasePaths, "multi-workspace", "workspace_vars")
+	assert.Equal(t, []ProjectFolder{
+		{Path: "db", WorkspaceList: []string{"dev", "prod"}},
+		{Path: "cache", WorkspaceList: []string{"prod", "dev"}},
+	}, updatedFoldersList)
+	assert.Equal(t, []string{"cache"}, removedDirs)
+
+	// Paths not confirmed on the merge-base are not stacks removed by the PR
+	foldersList = []ProjectFolder{{Path: "db", WorkspaceList: []string{"dev"}}}
+	updatedFoldersList, removedDirs = addRemovedProjectFolders(foldersList, changedFiles, nil, "multi-workspace", "workspace_vars")
+	assert.Equal(t, []ProjectFolder{{Path: "db", WorkspaceList: []string{"dev"}}}, updatedFoldersList)
+	assert.Empty(t, removedDirs)
+}
+
+// commitRepoFiles writes and removes files in the worktree of the repository and commits them.
+func commitRepoFiles(t *testing.T, repoPath string, worktree *git.Worktree, files map[string]string, removed ...string) {
+	for name, content := range files {
+		filePath := filepath.Join(repoPath, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		assert.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+		_, err := worktree.Add(name)
+		assert.NoError(t, err)
+	}
+	for _, name := range removed {
+		_, err := worktree.Remove(name)
+		assert.NoError(t, err)
+	}
+	_, err := worktree.Commit("test", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+}
+
+func TestGenerateAtlantisYAMLRenamedStack(t *testing.T) {
+	// The feature branch moves the old stack to a new folder
+	repoPath := t.TempDir()
+	repo, err := git.PlainInit(repoPath, false)
+	assert.NoError(t, err)
+	worktree, err := repo.Worktree()
+	assert.NoError(t, err)
+	commitRepoFiles(t, repoPath, worktree, map[string]string{
+		"app/main.tf": `resource "null_resource" "app" {}`,
+		"old/main.tf": `resource "null_resource" "stack" {}`,
+	})
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true})
+	assert.NoError(t, err)
+	commitRepoFiles(t, repoPath, worktree, map[string]string{
+		"new/main.tf": `resource "null_resource" "stack" {}`,
+	}, "old/main.tf")
+
+	outputFile := filepath.Join(t.TempDir(), "atlantis.yaml")
+	config.GlobalConfig.Parameters = map[string]string{
+		"terraform-base-dir":             repoPath,
+		"base-branch-name":               "master",
+		"discovery-mode":                 "single-workspace",
+		"pattern-detector":               "main.tf",
+		"name-collision-strategy":        "fail",
+		"pr-filter":                      "true",
+		"output-file":                    outputFile,
+		"output-type":                    "file",
+		"output-file-mode":               "0644",
+		"output-format":                  "yaml",
+		"automerge":                      "false",
+		"parallel-apply":                 "false",
+		"parallel-plan":                  "false",
+		"workspace-scoped-when-modified": "true",
+		"merge":                          "false",
+		"validate":                       "true",
+		"check":                          "false",
+		"header":                         "false",
+		"yaml-anchors":                   "false",
+		"force":                          "false",
+	}
+
+	// The rename keeps the project of the new folder and adds the one of the old folder to plan its destroy
+	assert.NoError(t, GenerateAtlantisYAML(NewChangeProvider("git")))
+	content, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "dir: new\n")
+	assert.Contains(t, string(content), "dir: old\n")
+	assert.NotContains(t, string(content), "dir: app\n")
+
+	// Without the merge-base the old folder can't be confirmed as a stack and is left out
+	config.GlobalConfig.Parameters["base-branch-name"] = ""
+	err = GenerateAtlantisYAML(scm.ProviderFunc(func() ([]scm.ChangedFile, error) {
+		return []scm.ChangedFile{{Path: "new/main.tf", Status: scm.StatusRenamed, PreviousPath: "old/main.tf"}}, nil
+	}))
+	assert.NoError(t, err)
+	content, err = os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "dir: new\n")
+	assert.NotContains(t, string(content), "dir: old\n")
+}
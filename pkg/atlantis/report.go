@@ -0,0 +1,186 @@
+package atlantis
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+)
+
+// githubStepSummary is the report file value used to append the report to the GitHub Actions job summary.
+const githubStepSummary = "github-step-summary"
+
+const allWorkspaces = "*"
+
+// Report is a human readable summary of the generated projects and of the dropped ones.
+type Report struct {
+	Projects        []Project
+	DroppedProjects []DroppedProject
+}
+
+// DroppedProject is a project, or a whole project folder, removed by one of the filters.
+type DroppedProject struct {
+	Name      string
+	Dir       string
+	Workspace string
+	Filter    string
+	Reason    string
+}
+
+const markdownReportTemplate = `## Atlantis projects
+
+{{ if .Projects -}}
+| Project | Dir | Workspace | Workflow | Autoplan |
+| ------- | --- | --------- | -------- | -------- |
+{{ range .Projects -}}
+| {{ cell .Name }} | {{ cell .Dir }} | {{ cell .Workspace }} | {{ cell .Workflow }} | {{ .Autoplan.Enabled }} |
+{{ end -}}
+{{ else -}}
+No projects were generated.
+{{ end -}}
+{{ if .DroppedProjects }}
+### Dropped projects
+
+| Project | Dir | Workspace | Filter | Reason |
+| ------- | --- | --------- | ------ | ------ |
+{{ range .DroppedProjects -}}
+| {{ cell .Name }} | {{ cell .Dir }} | {{ cell .Workspace }} | {{ cell .Filter }} | {{ cell .Reason }} |
+{{ end -}}
+{{ end -}}
+`
+
+const htmlReportTemplate = `<h2>Atlantis projects</h2>
+{{ if .Projects -}}
+<table>
+<tr><th>Project</th><th>Dir</th><th>Workspace</th><th>Workflow</th><th>Autoplan</th></tr>
+{{ range .Projects -}}
+<tr><td>{{ .Name }}</td><td>{{ .Dir }}</td><td>{{ .Workspace }}</td><td>{{ .Workflow }}</td><td>{{ .Autoplan.Enabled }}</td></tr>
+{{ end -}}
+</table>
+{{ else -}}
+<p>No projects were generated.</p>
+{{ end -}}
+{{ if .DroppedProjects -}}
+<h3>Dropped projects</h3>
+<table>
+<tr><th>Project</th><th>Dir</th><th>Workspace</th><th>Filter</th><th>Reason</th></tr>
+{{ range .DroppedProjects -}}
+<tr><td>{{ .Name }}</td><td>{{ .Dir }}</td><td>{{ .Workspace }}</td><td>{{ .Filter }}</td><td>{{ .Reason }}</td></tr>
+{{ end -}}
+</table>
+{{ end -}}
+`
+
+// addPRFilterDroppedFolders records the project folders without changes in the pull request.
+func (r *Report) addPRFilterDroppedFolders(folders, filteredFolders []ProjectFolder) {
+	kept := map[string]bool{}
+	for _, folder := range filteredFolders {
+		kept[folder.Path] = true
+	}
+	for _, folder := range folders {
+		if !kept[folder.Path] {
+			r.DroppedProjects = append(r.DroppedProjects, DroppedProject{
+				Dir:       folder.Path,
+				Workspace: allWorkspaces,
+				Filter:    "pr-filter",
+				Reason:    fmt.Sprintf("no file changed under %s/ in the pull request", folder.Path),
+			})
+		}
+	}
+}
+
+// addPRFilterDroppedWorkspaces records the workspaces removed by the PR filter in multi-workspace mode,
+// when only the var files of other workspaces were changed. They are named like in the resolved
+// projects, so the report matches the names after collision resolution.
+func (r *Report) addPRFilterDroppedWorkspaces(discoveryMode, patternDetector string, filteredFolders []ProjectFolder,
+	resolvedProjects []Project) error {
+	if discoveryMode != "multi-workspace" {
+		return nil
+	}
+	resolvedNames := make(map[string]string, len(resolvedProjects))
+	for _, project := range resolvedProjects {
+		resolvedNames[projectDirWorkspace(project)] = project.Name
+	}
+	for _, folder := range filteredFolders {
+		allWorkspaceList, err := multiWorkspaceGenWorkspaceList(
+			fmt.Sprintf("%s/%s", folder.Path, patternDetector), nil, false, "workspace")
+		if err != nil {
+			return err
+		}
+		for _, workspace := range allWorkspaceList {
+			if helpers.IsStringInList(workspace, folder.WorkspaceList) {
+				continue
+			}
+			r.DroppedProjects = append(r.DroppedProjects, DroppedProject{
+				Name:      resolvedNames[projectDirWorkspace(Project{Dir: folder.Path, Workspace: workspace})],
+				Dir:       folder.Path,
+				Workspace: workspace,
+				Filter:    "pr-filter",
+				Reason:    "only the var files of other workspaces changed in the pull request",
+			})
+		}
+	}
+	return nil
+}
+
+// addProjectFilterDropped records the projects removed by the included and excluded regex filters.
+func (r *Report) addProjectFilterDropped(projects, filteredProjects []Project, excludes, includes string) {
+	kept := map[string]bool{}
+	for _, project := range filteredProjects {
+		kept[projectDirWorkspace(project)] = true
+	}
+	for _, project := range projects {
+		if kept[projectDirWorkspace(project)] {
+			continue
+		}
+		dropped := DroppedProject{
+			Name:      project.Name,
+			Dir:       project.Dir,
+			Workspace: project.Workspace,
+			Filter:    "excluded-projects",
+			Reason:    fmt.Sprintf("name matches the excluded-projects regex '%s'", excludes),
+		}
+		// Regexes were already compiled by the project filter, so errors can't happen here
+		if included, _ := projectFilter(project.Name, "", includes); !included {
+			dropped.Filter = "included-projects"
+			dropped.Reason = fmt.Sprintf("name does not match the included-projects regex '%s'", includes)
+		}
+		r.DroppedProjects = append(r.DroppedProjects, dropped)
+	}
+}
+
+// markdownCell escapes a value so it stays in its markdown table cell,
+// since regexes and paths may contain pipes and newlines.
+func markdownCell(value string) string {
+	return strings.NewReplacer("|", "\\|", "\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+}
+
+func renderReport(report *Report, reportFormat string) ([]byte, error) {
+	var content strings.Builder
+	var err error
+	switch reportFormat {
+	case "markdown":
+		err = template.Must(template.New("report").
+			Funcs(template.FuncMap{"cell": markdownCell}).Parse(markdownReportTemplate)).Execute(&content, report)
+	case "html":
+		err = htmltemplate.Must(htmltemplate.New("report").Parse(htmlReportTemplate)).Execute(&content, report)
+	default:
+		return nil, fmt.Errorf("report format '%s' is not supported", reportFormat)
+	}
+	return []byte(content.String()), err
+}
+
+func writeReport(content []byte, reportFile string) error {
+	if reportFile != githubStepSummary {
+		return helpers.WriteFile(string(content), reportFile)
+	}
+	// The job summary file is shared by all steps of the job, so the report is appended
+	summaryFile := helpers.LookupEnvString("GITHUB_STEP_SUMMARY")
+	if summaryFile == "" {
+		return errors.New("GITHUB_STEP_SUMMARY environment variable is not set, report can't be written to the job summary")
+	}
+	return helpers.AppendFile(string(content), summaryFile)
+}
@@ -0,0 +1,186 @@
+package atlantis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportAddPRFilterDroppedFolders(t *testing.T) {
+	report := &Report{}
+	folders := []ProjectFolder{{Path: "a"}, {Path: "b"}}
+	report.addPRFilterDroppedFolders(folders, []ProjectFolder{{Path: "b"}})
+	assert.Equal(t, []DroppedProject{
+		{Dir: "a", Workspace: "*", Filter: "pr-filter", Reason: "no file changed under a/ in the pull request"},
+	}, report.DroppedProjects)
+}
+
+func TestReportAddPRFilterDroppedWorkspaces(t *testing.T) {
+	folders := []ProjectFolder{
+		{Path: "mockproject/multiworkspace2", WorkspaceList: []string{"test1"}},
+	}
+
+	// The names are the collision-resolved ones
+	resolvedProjects := []Project{
+		{Name: "mockproject-multiworkspace2-test1-1", Dir: "mockproject/multiworkspace2", Workspace: "test1"},
+		{Name: "mockproject-multiworkspace2-test2-2", Dir: "mockproject/multiworkspace2", Workspace: "test2"},
+	}
+
+	report := &Report{}
+	err := report.addPRFilterDroppedWorkspaces("multi-workspace", "workspace_vars", folders, resolvedProjects)
+	assert.NoError(t, err)
+	assert.Equal(t, []DroppedProject{
+		{
+			Name:      "mockproject-multiworkspace2-test2-2",
+			Dir:       "mockproject/multiworkspace2",
+			Workspace: "test2",
+			Filter:    "pr-filter",
+			Reason:    "only the var files of other workspaces changed in the pull request",
+		},
+	}, report.DroppedProjects)
+
+	// Single workspace projects have no workspaces to drop
+	report = &Report{}
+	err = report.addPRFilterDroppedWorkspaces("single-workspace", "main.tf", folders, resolvedProjects)
+	assert.NoError(t, err)
+	assert.Empty(t, report.DroppedProjects)
+}
+
+func TestReportAddProjectFilterDropped(t *testing.T) {
+	projects := []Project{
+		{Name: "app-dev", Dir: "app", Workspace: "dev"},
+		{Name: "app-prod", Dir: "app", Workspace: "prod"},
+		{Name: "db", Dir: "db", Workspace: "default"},
+	}
+
+	report := &Report{}
+	report.addProjectFilterDropped(projects, projects[:1], "prod", "^app")
+	assert.Equal(t, []DroppedProject{
+		{Name: "app-prod", Dir: "app", Workspace: "prod", Filter: "excluded-projects",
+			Reason: "name matches the excluded-projects regex 'prod'"},
+		{Name: "db", Dir: "db", Workspace: "default", Filter: "included-projects",
+			Reason: "name does not match the included-projects regex '^app'"},
+	}, report.DroppedProjects)
+}
+
+func TestRenderReport(t *testing.T) {
+	report := &Report{
+		Projects: []Project{
+			{Name: "app", Dir: "app", Workspace: "default", Workflow: "custom", Autoplan: Autoplan{Enabled: true}},
+		},
+		DroppedProjects: []DroppedProject{
+			{Dir: "db", Workspace: "*", Filter: "pr-filter", Reason: "no file changed under db/ in the pull request"},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		report         *Report
+		reportFormat   string
+		expectedOutput string
+		expectedError  bool
+	}{
+		{
+			name:         "Markdown",
+			report:       report,
+			reportFormat: "markdown",
+			expectedOutput: `## Atlantis projects
+
+| Project | Dir | Workspace | Workflow | Autoplan |
+| ------- | --- | --------- | -------- | -------- |
+| app | app | default | custom | true |
+
+### Dropped projects
+
+| Project | Dir | Workspace | Filter | Reason |
+| ------- | --- | --------- | ------ | ------ |
+|  | db | * | pr-filter | no file changed under db/ in the pull request |
+`,
+		},
+		{
+			name:         "MarkdownNoProjects",
+			report:       &Report{},
+			reportFormat: "markdown",
+			expectedOutput: `## Atlantis projects
+
+No projects were generated.
+`,
+		},
+		{
+			name: "MarkdownEscaping",
+			report: &Report{
+				DroppedProjects: []DroppedProject{{
+					Name: "app|dev", Dir: "app", Workspace: "dev", Filter: "excluded-projects",
+					Reason: "name matches the excluded-projects regex '^(app|db)\n'",
+				}},
+			},
+			reportFormat: "markdown",
+			expectedOutput: `## Atlantis projects
+
+No projects were generated.
+
+### Dropped projects
+
+| Project | Dir | Workspace | Filter | Reason |
+| ------- | --- | --------- | ------ | ------ |
+| app\|dev | app | dev | excluded-projects | name matches the excluded-projects regex '^(app\|db) ' |
+`,
+		},
+		{
+			name:         "HTML",
+			report:       &Report{Projects: []Project{{Name: "<app>", Dir: "app", Workspace: "default"}}},
+			reportFormat: "html",
+			expectedOutput: `<h2>Atlantis projects</h2>
+<table>
+<tr><th>Project</th><th>Dir</th><th>Workspace</th><th>Workflow</th><th>Autoplan</th></tr>
+<tr><td>&lt;app&gt;</td><td>app</td><td>default</td><td></td><td>false</td></tr>
+</table>
+`,
+		},
+		{
+			name:          "UnsupportedFormat",
+			report:        report,
+			reportFormat:  "pdf",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := renderReport(tc.report, tc.reportFormat)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedOutput, string(output))
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	summaryFile := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryFile)
+
+	// The job summary is appended to the content written by previous steps
+	err := os.WriteFile(summaryFile, []byte("previous step\n"), 0644)
+	assert.NoError(t, err)
+	err = writeReport([]byte("report\n"), "github-step-summary")
+	assert.NoError(t, err)
+	content, err := os.ReadFile(summaryFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "previous step\nreport\n", string(content))
+
+	t.Setenv("GITHUB_STEP_SUMMARY", "")
+	err = writeReport([]byte("report\n"), "github-step-summary")
+	assert.Error(t, err)
+
+	reportFile := filepath.Join(t.TempDir(), "report.md")
+	err = writeReport([]byte("report\n"), reportFile)
+	assert.NoError(t, err)
+	content, err = os.ReadFile(reportFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "report\n", string(content))
+}
@@ -0,0 +1,184 @@
+package atlantis
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+// repoConfigSchema is the JSON Schema of the Atlantis repo level config (atlantis.yaml) version 3.
+//
+//go:embed schema/atlantis-repo-config-v3.json
+var repoConfigSchema string
+
+const repoConfigSchemaURL = "atlantis-repo-config-v3.json"
+
+// validationProject holds the project fields checked beyond the JSON Schema.
+type validationProject struct {
+	Name      string   `yaml:"name"`
+	Dir       string   `yaml:"dir"`
+	Workspace string   `yaml:"workspace"`
+	Workflow  string   `yaml:"workflow"`
+	DependsOn []string `yaml:"depends_on"`
+}
+
+type validationConfig struct {
+	Projects  []validationProject    `yaml:"projects"`
+	Workflows map[string]interface{} `yaml:"workflows"`
+}
+
+// validateOutputYAML checks the rendered atlantis.yaml content before it is written,
+// so invalid files are not only discovered when Atlantis rejects the pull request.
+// Dirs missing on disk are only accepted when they are the folders of stacks removed by the PR,
+// confirmed on its merge-base.
+func validateOutputYAML(content []byte, baseDir, serverSideWorkflows string, mergeBaseDirs []string) error {
+	var failures []string
+
+	var document interface{}
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		return err
+	}
+	var config validationConfig
+	err = yaml.Unmarshal(content, &config)
+	if err != nil {
+		return err
+	}
+
+	schemaFailures, err := validateRepoConfigSchema(document, config.Projects)
+	if err != nil {
+		return err
+	}
+	failures = append(failures, schemaFailures...)
+	failures = append(failures, validateProjects(config, baseDir, serverSideWorkflows, mergeBaseDirs)...)
+
+	if len(failures) > 0 {
+		return fmt.Errorf("generated config is not a valid Atlantis repo config:\n  - %s",
+			strings.Join(failures, "\n  - "))
+	}
+	return nil
+}
+
+func validateRepoConfigSchema(document interface{}, projects []validationProject) ([]string, error) {
+	compiler := jsonschema.NewCompiler()
+	err := compiler.AddResource(repoConfigSchemaURL, strings.NewReader(repoConfigSchema))
+	if err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(repoConfigSchemaURL)
+	if err != nil {
+		return nil, err
+	}
+	// The validator works on JSON values, so the YAML document is converted first
+	jsonContent, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var jsonDocument interface{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonContent))
+	decoder.UseNumber()
+	err = decoder.Decode(&jsonDocument)
+	if err != nil {
+		return nil, err
+	}
+
+	err = schema.Validate(jsonDocument)
+	var validationError *jsonschema.ValidationError
+	if errors.As(err, &validationError) {
+		return schemaFailures(validationError, projects), nil
+	}
+	return nil, err
+}
+
+// schemaFailures flattens the validation error into one message per failing leaf.
+func schemaFailures(validationError *jsonschema.ValidationError, projects []validationProject) []string {
+	if len(validationError.Causes) == 0 {
+		location := validationError.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return []string{fmt.Sprintf("%s%s: %s",
+			projectFailurePrefix(location, projects), location, validationError.Message)}
+	}
+	var failures []string
+	for _, cause := range validationError.Causes {
+		failures = append(failures, schemaFailures(cause, projects)...)
+	}
+	return failures
+}
+
+// projectFailurePrefix names the project a JSON pointer such as /projects/3/dir refers to.
+func projectFailurePrefix(instanceLocation string, projects []validationProject) string {
+	parts := strings.Split(instanceLocation, "/")
+	if len(parts) < 3 || parts[1] != projectsKey {
+		return ""
+	}
+	index, err := strconv.Atoi(parts[2])
+	if err != nil || index >= len(projects) {
+		return ""
+	}
+	return fmt.Sprintf("project '%s' ", projects[index].Name)
+}
+
+func validateProjects(config validationConfig, baseDir, serverSideWorkflows string, mergeBaseDirs []string) (failures []string) {
+	workflows := map[string]bool{}
+	for name := range config.Workflows {
+		workflows[name] = true
+	}
+	for _, name := range strings.Split(serverSideWorkflows, ",") {
+		if name != "" {
+			workflows[name] = true
+		}
+	}
+
+	projectNames := map[string]int{}
+	for _, project := range config.Projects {
+		if project.Name != "" {
+			projectNames[project.Name]++
+		}
+	}
+
+	for _, project := range config.Projects {
+		prefix := fmt.Sprintf("project '%s'", project.Name)
+		if project.Name != "" && projectNames[project.Name] > 1 {
+			failures = append(failures, fmt.Sprintf("%s: name is not unique", prefix))
+		}
+		if project.Dir != "" {
+			info, err := os.Stat(filepath.Join(baseDir, project.Dir))
+			missing := err != nil || !info.IsDir()
+			if missing && !helpers.IsStringInList(project.Dir, mergeBaseDirs) {
+				failures = append(failures, fmt.Sprintf("%s: dir '%s' does not exist", prefix, project.Dir))
+			}
+		}
+		if project.Workspace != "" && !isValidWorkspaceName(project.Workspace) {
+			failures = append(failures, fmt.Sprintf("%s: workspace '%s' must contain only URL safe characters and no path separators",
+				prefix, project.Workspace))
+		}
+		if project.Workflow != "" && !workflows[project.Workflow] {
+			failures = append(failures, fmt.Sprintf("%s: workflow '%s' is not defined in workflows nor in server-side-workflows",
+				prefix, project.Workflow))
+		}
+		for _, dependency := range project.DependsOn {
+			if projectNames[dependency] == 0 {
+				failures = append(failures, fmt.Sprintf("%s: depends_on '%s' is not a project", prefix, dependency))
+			}
+		}
+	}
+	return failures
+}
+
+// isValidWorkspaceName applies the Terraform workspace naming rules.
+func isValidWorkspaceName(workspace string) bool {
+	return url.PathEscape(workspace) == workspace
+}
@@ -0,0 +1,123 @@
+package atlantis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOutputYAML(t *testing.T) {
+	testCases := []struct {
+		name                string
+		content             string
+		serverSideWorkflows string
+		expectedFailures    []string
+	}{
+		{
+			name: "ValidConfig",
+			content: `version: 3
+automerge: true
+projects:
+    - name: singleworkspace
+      dir: singleworkspace
+      workspace: default
+      workflow: custom
+      apply_requirements: [approved]
+      autoplan:
+        enabled: true
+        when_modified: ['*.tf']
+    - name: multiworkspace-test1
+      dir: multiworkspace
+      workspace: test1
+      workflow: server
+      depends_on: [singleworkspace]
+workflows:
+    custom:
+        plan:
+            steps:
+                - init
+                - run: echo plan
+                - plan:
+                    extra_args: [-lock=false]
+`,
+			serverSideWorkflows: "server,other",
+		},
+		{
+			name: "SchemaFailures",
+			content: `version: 2
+unknown_key: true
+projects:
+    - name: app
+      dir: singleworkspace
+      apply_requirements: [reviewed]
+    - name: nodir
+`,
+			expectedFailures: []string{
+				"/version",
+				"unknown_key",
+				"project 'app' /projects/0/apply_requirements/0",
+				"project 'nodir' /projects/1: missing properties: 'dir'",
+			},
+		},
+		{
+			name: "ProjectFailures",
+			content: `version: 3
+projects:
+    - name: app
+      dir: missing
+      workspace: prod/eu
+      workflow: undefined
+      depends_on: [ghost]
+    - name: app
+      dir: singleworkspace
+`,
+			expectedFailures: []string{
+				"project 'app': name is not unique",
+				"project 'app': dir 'missing' does not exist",
+				"project 'app': workspace 'prod/eu' must contain only URL safe characters and no path separators",
+				"project 'app': workflow 'undefined' is not defined in workflows nor in server-side-workflows",
+				"project 'app': depends_on 'ghost' is not a project",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateOutputYAML([]byte(tc.content), "mockproject", tc.serverSideWorkflows, nil)
+			if len(tc.expectedFailures) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			for _, failure := range tc.expectedFailures {
+				assert.Contains(t, err.Error(), failure)
+			}
+		})
+	}
+}
+
+func TestValidateGeneratedConfig(t *testing.T) {
+	config := &Config{
+		Version: 3,
+		Projects: []Project{
+			{Name: "singleworkspace", Workspace: "default", Dir: "singleworkspace",
+				Autoplan: Autoplan{Enabled: true, WhenModified: []string{"*.tf"}}},
+		},
+	}
+	yamlBytes, err := renderOutputYAML(config, "", "false", "", "", "false")
+	assert.NoError(t, err)
+	assert.NoError(t, validateOutputYAML(yamlBytes, "mockproject", "", nil))
+
+	// Projects removed by the PR only need their dir on the merge-base
+	config.Projects[0].Dir = "removed"
+	yamlBytes, err = renderOutputYAML(config, "", "false", "", "", "false")
+	assert.NoError(t, err)
+	assert.Error(t, validateOutputYAML(yamlBytes, "mockproject", "", nil))
+	assert.NoError(t, validateOutputYAML(yamlBytes, "mockproject", "", []string{"removed"}))
+}
+
+func TestIsValidWorkspaceName(t *testing.T) {
+	assert.True(t, isValidWorkspaceName("prod-eu_1.x~"))
+	assert.False(t, isValidWorkspaceName("prod/eu"))
+	assert.False(t, isValidWorkspaceName("prod eu"))
+}
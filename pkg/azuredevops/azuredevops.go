@@ -0,0 +1,172 @@
+package azuredevops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+const (
+	defaultHostname = "dev.azure.com"
+	apiVersion      = "7.0"
+)
+
+type iterations struct {
+	Value []struct {
+		ID int `json:"id"`
+	} `json:"value"`
+}
+
+// ChangeEntry is a changed file of a pull request iteration.
+// Paths are absolute in the repository, and OriginalPath is only set for renames.
+type ChangeEntry struct {
+	ChangeType   string `json:"changeType"`
+	OriginalPath string `json:"originalPath"`
+	Item         struct {
+		Path     string `json:"path"`
+		IsFolder bool   `json:"isFolder"`
+	} `json:"item"`
+}
+
+type iterationChanges struct {
+	ChangeEntries []ChangeEntry `json:"changeEntries"`
+	NextSkip      int           `json:"nextSkip"`
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// runADORequest returns the list of changed files in the latest iteration of a pull request.
+func runADORequest(baseURL, authToken, organization, project, repo, pullReqNum string) ([]scm.ChangedFile, error) {
+	var changedFiles []scm.ChangedFile
+	if _, err := strconv.Atoi(pullReqNum); err != nil {
+		return nil, err
+	}
+	pullRequestURL := fmt.Sprintf("%s/%s/%s/_apis/git/repositories/%s/pullRequests/%s",
+		baseURL, url.PathEscape(organization), url.PathEscape(project), url.PathEscape(repo), pullReqNum)
+
+	var prIterations iterations
+	err := getJSON(fmt.Sprintf("%s/iterations?api-version=%s", pullRequestURL, apiVersion), authToken, &prIterations)
+	if err != nil {
+		return nil, err
+	}
+	latestIteration := 0
+	for _, iteration := range prIterations.Value {
+		if iteration.ID > latestIteration {
+			latestIteration = iteration.ID
+		}
+	}
+	if latestIteration == 0 {
+		return nil, fmt.Errorf("pull request %s has no iterations", pullReqNum)
+	}
+
+	// Changes are paginated, the next page starts at nextSkip until it is 0
+	skip := 0
+	for {
+		var changes iterationChanges
+		err = getJSON(fmt.Sprintf("%s/iterations/%d/changes?api-version=%s&$top=100&$skip=%d",
+			pullRequestURL, latestIteration, apiVersion, skip), authToken, &changes)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range changes.ChangeEntries {
+			if change.Item.IsFolder {
+				continue
+			}
+			changedFiles = append(changedFiles, change.changedFile())
+		}
+		if changes.NextSkip == 0 {
+			return changedFiles, nil
+		}
+		skip = changes.NextSkip
+	}
+}
+
+// changedFile returns the normalized changed file of a change entry. The change type
+// is a flags list, i.e. "edit, rename", renames take precedence.
+func (change ChangeEntry) changedFile() scm.ChangedFile {
+	path := strings.TrimPrefix(change.Item.Path, "/")
+	switch {
+	case strings.Contains(change.ChangeType, "rename") && change.OriginalPath != "" && change.OriginalPath != change.Item.Path:
+		return scm.ChangedFile{Path: path, Status: scm.StatusRenamed, PreviousPath: strings.TrimPrefix(change.OriginalPath, "/")}
+	case strings.Contains(change.ChangeType, "delete"):
+		return scm.ChangedFile{Path: path, Status: scm.StatusRemoved}
+	case strings.Contains(change.ChangeType, "add"):
+		return scm.ChangedFile{Path: path, Status: scm.StatusAdded}
+	default:
+		return scm.ChangedFile{Path: path, Status: scm.StatusModified}
+	}
+}
+
+// getJSON decodes the response of a GET request authenticated with a personal access token.
+func getJSON(requestURL, authToken string, target interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("", authToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", requestURL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// GetChangedFiles returns the changed files of an Azure DevOps pull request.
+// Like in Atlantis, base-repo-owner is "<organization>/<project>" and base-repo-name the repository.
+func GetChangedFiles() (ChangedFiles []scm.ChangedFile, err error) {
+	token := config.GlobalConfig.Parameters["azuredevops-token"]
+	if token == "" {
+		token = helpers.LookupEnvString("ATLANTIS_AZUREDEVOPS_TOKEN")
+	}
+	if token == "" {
+		err = errors.New("azuredevops-token is not set.\n" +
+			"Please use azuredevops-token parameter or AZUREDEVOPS_TOKEN environment variable to set the token.")
+		return ChangedFiles, err
+	}
+	hostname := config.GlobalConfig.Parameters["azuredevops-hostname"]
+	if hostname == "" {
+		hostname = helpers.LookupEnvString("ATLANTIS_AZUREDEVOPS_HOSTNAME")
+	}
+	organization, project, found := strings.Cut(config.GlobalConfig.Parameters["base-repo-owner"], "/")
+	if !found {
+		err = fmt.Errorf("base-repo-owner '%s' is not in the <organization>/<project> format",
+			config.GlobalConfig.Parameters["base-repo-owner"])
+		return ChangedFiles, err
+	}
+	prChangedFiles, err := runADORequest(
+		baseURL(hostname),
+		token,
+		organization,
+		project,
+		config.GlobalConfig.Parameters["base-repo-name"],
+		config.GlobalConfig.Parameters["pull-num"])
+	if err != nil {
+		return []scm.ChangedFile{}, err
+	}
+	return prChangedFiles, err
+}
+
+// baseURL returns the Azure DevOps server URL, the hostname may include a scheme.
+func baseURL(hostname string) string {
+	if hostname == "" {
+		hostname = defaultHostname
+	}
+	if !strings.Contains(hostname, "://") {
+		hostname = "https://" + hostname
+	}
+	return strings.TrimSuffix(hostname, "/")
+}
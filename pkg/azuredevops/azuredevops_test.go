@@ -0,0 +1,92 @@
+package azuredevops
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+func newADOServer(t *testing.T) *httptest.Server {
+	const pullRequestPath = "/org/My Project/_apis/git/repositories/repo/pullRequests/9"
+	pages := map[string]string{
+		"0": `{"changeEntries": [
+			{"changeType": "edit", "item": {"path": "/app/main.tf"}},
+			{"changeType": "add", "item": {"path": "/db", "isFolder": true}},
+			{"changeType": "rename", "originalPath": "/old/main.tf", "item": {"path": "/db/main.tf"}}],
+			"nextSkip": 3, "nextTop": 100}`,
+		"3": `{"changeEntries": [{"changeType": "delete", "item": {"path": "/network/main.tf"}}], "nextSkip": 0}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, ok := r.BasicAuth()
+		if !ok || password != "test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case pullRequestPath + "/iterations":
+			fmt.Fprint(w, `{"value": [{"id": 1}, {"id": 3}, {"id": 2}], "count": 3}`)
+		case pullRequestPath + "/iterations/3/changes":
+			fmt.Fprint(w, pages[r.URL.Query().Get("$skip")])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestRunADORequest(t *testing.T) {
+	server := newADOServer(t)
+	defer server.Close()
+
+	changedFiles, err := runADORequest(server.URL, "test-token", "org", "My Project", "repo", "9")
+	assert.NoError(t, err)
+	assert.Equal(t, []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "db/main.tf", Status: scm.StatusRenamed, PreviousPath: "old/main.tf"},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+	}, changedFiles)
+
+	_, err = runADORequest(server.URL, "wrong-token", "org", "My Project", "repo", "9")
+	assert.Error(t, err)
+
+	_, err = runADORequest(server.URL, "test-token", "org", "My Project", "repo", "nine")
+	assert.Error(t, err)
+}
+
+func TestGetChangedFiles(t *testing.T) {
+	server := newADOServer(t)
+	defer server.Close()
+
+	// Server settings fall back to the Atlantis ones
+	t.Setenv("ATLANTIS_AZUREDEVOPS_HOSTNAME", server.URL)
+	t.Setenv("ATLANTIS_AZUREDEVOPS_TOKEN", "test-token")
+	config.GlobalConfig.Parameters = map[string]string{
+		"base-repo-owner": "org/My Project",
+		"base-repo-name":  "repo",
+		"pull-num":        "9",
+	}
+	changedFiles, err := GetChangedFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "db/main.tf", Status: scm.StatusRenamed, PreviousPath: "old/main.tf"},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+	}, changedFiles)
+
+	config.GlobalConfig.Parameters["base-repo-owner"] = "org"
+	_, err = GetChangedFiles()
+	assert.Error(t, err)
+
+	t.Setenv("ATLANTIS_AZUREDEVOPS_TOKEN", "")
+	_, err = GetChangedFiles()
+	assert.Error(t, err)
+}
+
+func TestBaseURL(t *testing.T) {
+	assert.Equal(t, "https://dev.azure.com", baseURL(""))
+	assert.Equal(t, "https://ado.example.com", baseURL("ado.example.com/"))
+}
@@ -0,0 +1,232 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+const cloudBaseURL = "https://api.bitbucket.org"
+
+// DiffStat is a changed file of a Bitbucket Cloud pull request. Old is empty for added
+// files and New is empty for removed ones.
+type DiffStat struct {
+	Status string        `json:"status"`
+	Old    *DiffStatFile `json:"old"`
+	New    *DiffStatFile `json:"new"`
+}
+
+// DiffStatFile is the file of one side of a diffstat.
+type DiffStatFile struct {
+	Path string `json:"path"`
+}
+
+type diffStatPage struct {
+	Values []DiffStat `json:"values"`
+	Next   string     `json:"next"`
+}
+
+// Change is a changed file of a Bitbucket Data Center pull request.
+type Change struct {
+	Type    string `json:"type"`
+	Path    Path   `json:"path"`
+	SrcPath *Path  `json:"srcPath"`
+}
+
+// Path is a file path of a Bitbucket Data Center change.
+type Path struct {
+	ToString string `json:"toString"`
+}
+
+type changePage struct {
+	Values        []Change `json:"values"`
+	IsLastPage    bool     `json:"isLastPage"`
+	NextPageStart int      `json:"nextPageStart"`
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// runCloudRequest returns the list of changed files in a Bitbucket Cloud pull request.
+// The diffstat API is paginated, pages are followed through the next URL.
+func runCloudRequest(baseURL, user, authToken, workspace, repo, pullReqNum string) ([]scm.ChangedFile, error) {
+	var changedFiles []scm.ChangedFile
+	if _, err := strconv.Atoi(pullReqNum); err != nil {
+		return nil, err
+	}
+	requestURL := fmt.Sprintf("%s/2.0/repositories/%s/%s/pullrequests/%s/diffstat?pagelen=100",
+		baseURL, url.PathEscape(workspace), url.PathEscape(repo), pullReqNum)
+	for requestURL != "" {
+		var page diffStatPage
+		err := getJSON(requestURL, user, authToken, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, diffStat := range page.Values {
+			if changedFile, ok := diffStat.changedFile(); ok {
+				changedFiles = append(changedFiles, changedFile)
+			}
+		}
+		requestURL = page.Next
+	}
+	return changedFiles, nil
+}
+
+// runDataCenterRequest returns the list of changed files in a Bitbucket Data Center pull request.
+// The changes API is paginated, pages are followed from the next page start.
+func runDataCenterRequest(baseURL, user, authToken, project, repo, pullReqNum string) ([]scm.ChangedFile, error) {
+	var changedFiles []scm.ChangedFile
+	if _, err := strconv.Atoi(pullReqNum); err != nil {
+		return nil, err
+	}
+	start := 0
+	for {
+		requestURL := fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/pull-requests/%s/changes?limit=100&start=%d",
+			baseURL, url.PathEscape(project), url.PathEscape(repo), pullReqNum, start)
+		var page changePage
+		err := getJSON(requestURL, user, authToken, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range page.Values {
+			changedFiles = append(changedFiles, change.changedFile())
+		}
+		if page.IsLastPage || len(page.Values) == 0 {
+			return changedFiles, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// changedFile returns the normalized changed file of a diffstat, diffstats without paths are skipped.
+func (diffStat DiffStat) changedFile() (scm.ChangedFile, bool) {
+	switch {
+	case diffStat.New == nil && diffStat.Old == nil:
+		return scm.ChangedFile{}, false
+	case diffStat.New == nil:
+		return scm.ChangedFile{Path: diffStat.Old.Path, Status: scm.StatusRemoved}, true
+	case diffStat.Old == nil:
+		return scm.ChangedFile{Path: diffStat.New.Path, Status: scm.StatusAdded}, true
+	case diffStat.Old.Path != diffStat.New.Path:
+		return scm.ChangedFile{Path: diffStat.New.Path, Status: scm.StatusRenamed, PreviousPath: diffStat.Old.Path}, true
+	default:
+		return scm.ChangedFile{Path: diffStat.New.Path, Status: scm.StatusModified}, true
+	}
+}
+
+// changedFile returns the normalized changed file of a Data Center change.
+func (change Change) changedFile() scm.ChangedFile {
+	switch change.Type {
+	case "ADD", "COPY":
+		return scm.ChangedFile{Path: change.Path.ToString, Status: scm.StatusAdded}
+	case "DELETE":
+		return scm.ChangedFile{Path: change.Path.ToString, Status: scm.StatusRemoved}
+	case "MOVE":
+		if change.SrcPath != nil && change.SrcPath.ToString != change.Path.ToString {
+			return scm.ChangedFile{Path: change.Path.ToString, Status: scm.StatusRenamed, PreviousPath: change.SrcPath.ToString}
+		}
+	}
+	return scm.ChangedFile{Path: change.Path.ToString, Status: scm.StatusModified}
+}
+
+// getJSON decodes the response of an authenticated GET request. Requests use basic auth
+// with the user and token (app password) when a user is set, or the token as bearer token.
+func getJSON(requestURL, user, authToken string, target interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	if user != "" {
+		req.SetBasicAuth(user, authToken)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+authToken)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", requestURL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// GetCloudChangedFiles returns the changed files of a Bitbucket Cloud pull request.
+// base-repo-owner is the workspace and base-repo-name the repository slug.
+func GetCloudChangedFiles() (ChangedFiles []scm.ChangedFile, err error) {
+	user, token, baseURL, err := credentials(cloudBaseURL)
+	if err != nil {
+		return ChangedFiles, err
+	}
+	prChangedFiles, err := runCloudRequest(
+		baseURL,
+		user,
+		token,
+		config.GlobalConfig.Parameters["base-repo-owner"],
+		config.GlobalConfig.Parameters["base-repo-name"],
+		config.GlobalConfig.Parameters["pull-num"])
+	if err != nil {
+		return []scm.ChangedFile{}, err
+	}
+	return prChangedFiles, err
+}
+
+// GetDataCenterChangedFiles returns the changed files of a Bitbucket Data Center pull request.
+// base-repo-owner is the project key and base-repo-name the repository slug.
+func GetDataCenterChangedFiles() (ChangedFiles []scm.ChangedFile, err error) {
+	user, token, baseURL, err := credentials("")
+	if err != nil {
+		return ChangedFiles, err
+	}
+	if baseURL == "" {
+		err = errors.New("bitbucket-base-url is not set.\n" +
+			"Please use bitbucket-base-url parameter or BITBUCKET_BASE_URL environment variable to set the server URL.")
+		return ChangedFiles, err
+	}
+	prChangedFiles, err := runDataCenterRequest(
+		baseURL,
+		user,
+		token,
+		config.GlobalConfig.Parameters["base-repo-owner"],
+		config.GlobalConfig.Parameters["base-repo-name"],
+		config.GlobalConfig.Parameters["pull-num"])
+	if err != nil {
+		return []scm.ChangedFile{}, err
+	}
+	return prChangedFiles, err
+}
+
+// credentials returns the Bitbucket settings, falling back to the ones of the Atlantis server.
+func credentials(defaultBaseURL string) (user, token, baseURL string, err error) {
+	user = config.GlobalConfig.Parameters["bitbucket-user"]
+	if user == "" {
+		user = helpers.LookupEnvString("ATLANTIS_BITBUCKET_USER")
+	}
+	token = config.GlobalConfig.Parameters["bitbucket-token"]
+	if token == "" {
+		token = helpers.LookupEnvString("ATLANTIS_BITBUCKET_TOKEN")
+	}
+	if token == "" {
+		err = errors.New("bitbucket-token is not set.\n" +
+			"Please use bitbucket-token parameter or BITBUCKET_TOKEN environment variable to set the token.")
+		return user, token, baseURL, err
+	}
+	baseURL = config.GlobalConfig.Parameters["bitbucket-base-url"]
+	if baseURL == "" {
+		baseURL = helpers.LookupEnvString("ATLANTIS_BITBUCKET_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return user, token, strings.TrimSuffix(baseURL, "/"), nil
+}
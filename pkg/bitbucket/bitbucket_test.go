@@ -0,0 +1,144 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+func newCloudServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "test-user" || password != "test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/2.0/repositories/workspace/repo/pullrequests/3/diffstat" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("page") == "" {
+			fmt.Fprintf(w, `{"values": [
+				{"status": "modified", "old": {"path": "app/main.tf"}, "new": {"path": "app/main.tf"}},
+				{"status": "added", "old": null, "new": {"path": "db/main.tf"}}],
+				"next": "%s/2.0/repositories/workspace/repo/pullrequests/3/diffstat?pagelen=100&page=2"}`, server.URL)
+			return
+		}
+		fmt.Fprint(w, `{"values": [{"status": "removed", "old": {"path": "network/main.tf"}, "new": null}]}`)
+	}))
+	return server
+}
+
+func newDataCenterServer(t *testing.T) *httptest.Server {
+	pages := map[string]string{
+		"0": `{"values": [
+			{"type": "MODIFY", "path": {"toString": "app/main.tf"}},
+			{"type": "MOVE", "path": {"toString": "db/main.tf"}, "srcPath": {"toString": "old/main.tf"}}],
+			"isLastPage": false, "nextPageStart": 2}`,
+		"2": `{"values": [{"type": "DELETE", "path": {"toString": "network/main.tf"}}], "isLastPage": true}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/rest/api/1.0/projects/PRJ/repos/repo/pull-requests/3/changes" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, pages[r.URL.Query().Get("start")])
+	}))
+}
+
+func TestRunCloudRequest(t *testing.T) {
+	server := newCloudServer(t)
+	defer server.Close()
+
+	changedFiles, err := runCloudRequest(server.URL, "test-user", "test-token", "workspace", "repo", "3")
+	assert.NoError(t, err)
+	assert.Equal(t, []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "db/main.tf", Status: scm.StatusAdded},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+	}, changedFiles)
+
+	_, err = runCloudRequest(server.URL, "test-user", "wrong-token", "workspace", "repo", "3")
+	assert.Error(t, err)
+
+	_, err = runCloudRequest(server.URL, "test-user", "test-token", "workspace", "repo", "three")
+	assert.Error(t, err)
+}
+
+func TestRunDataCenterRequest(t *testing.T) {
+	server := newDataCenterServer(t)
+	defer server.Close()
+
+	changedFiles, err := runDataCenterRequest(server.URL, "", "test-token", "PRJ", "repo", "3")
+	assert.NoError(t, err)
+	assert.Equal(t, []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "db/main.tf", Status: scm.StatusRenamed, PreviousPath: "old/main.tf"},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+	}, changedFiles)
+
+	_, err = runDataCenterRequest(server.URL, "", "test-token", "PRJ", "missing", "3")
+	assert.Error(t, err)
+}
+
+func TestGetCloudChangedFiles(t *testing.T) {
+	server := newCloudServer(t)
+	defer server.Close()
+
+	// Credentials fall back to the Atlantis settings
+	t.Setenv("ATLANTIS_BITBUCKET_USER", "test-user")
+	t.Setenv("ATLANTIS_BITBUCKET_TOKEN", "test-token")
+	t.Setenv("ATLANTIS_BITBUCKET_BASE_URL", server.URL+"/")
+	config.GlobalConfig.Parameters = map[string]string{
+		"base-repo-owner": "workspace",
+		"base-repo-name":  "repo",
+		"pull-num":        "3",
+	}
+	changedFiles, err := GetCloudChangedFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "db/main.tf", Status: scm.StatusAdded},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+	}, changedFiles)
+
+	t.Setenv("ATLANTIS_BITBUCKET_TOKEN", "")
+	_, err = GetCloudChangedFiles()
+	assert.Error(t, err)
+}
+
+func TestGetDataCenterChangedFiles(t *testing.T) {
+	server := newDataCenterServer(t)
+	defer server.Close()
+
+	t.Setenv("ATLANTIS_BITBUCKET_USER", "")
+	t.Setenv("ATLANTIS_BITBUCKET_BASE_URL", "")
+	config.GlobalConfig.Parameters = map[string]string{
+		"bitbucket-token": "test-token",
+		"base-repo-owner": "PRJ",
+		"base-repo-name":  "repo",
+		"pull-num":        "3",
+	}
+	// Data Center has no default server
+	_, err := GetDataCenterChangedFiles()
+	assert.Error(t, err)
+
+	config.GlobalConfig.Parameters["bitbucket-base-url"] = server.URL
+	changedFiles, err := GetDataCenterChangedFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "db/main.tf", Status: scm.StatusRenamed, PreviousPath: "old/main.tf"},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+	}, changedFiles)
+}
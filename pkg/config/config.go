@@ -10,6 +10,9 @@ import (
 
 type Config struct {
 	Parameters map[string]string
+	// ExplicitParameters are the parameters set with a flag or an environment variable,
+	// rather than left to their default value
+	ExplicitParameters map[string]bool
 }
 
 var GlobalConfig Config
@@ -22,11 +25,19 @@ type Parameter struct {
 	DefaultValue string
 	Shorthand    string
 	Value        string
+	// Secret parameters are never written to the generated files
+	Secret bool
+	// PerRun parameters change with every pull request, so they are never written to the generated files
+	PerRun bool
 }
 
 type DependentParameters struct {
 	WhenParentParameterIs string
 	ParameterList         []string
+	// SelectorParameter picks the dependent parameters by its value in SelectorParameterLists,
+	// ParameterList applies to the values which are not listed
+	SelectorParameter      string
+	SelectorParameterLists map[string][]string
 }
 
 // ParameterList is the source of truth list for all parameters
@@ -68,11 +79,111 @@ var ParameterList = []Parameter{
 	},
 	{
 		Name:         "output-type",
-		Description:  "Atlantis YAML output type. [file|stdout].",
+		Description:  "Output destination. [file|stdout].",
 		Required:     false,
 		DefaultValue: "file",
 		Shorthand:    "e",
 	},
+	{
+		Name:         "base-config",
+		Description:  "YAML file used as skeleton of the output (workflows, repo level settings and project_defaults), only projects are generated.",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "check",
+		Description:  "Compare the generated config with the existing output file instead of writing it, print a diff and fail if they differ.",
+		Required:     false,
+		DefaultValue: "false",
+		Shorthand:    "",
+		PerRun:       true,
+	},
+	{
+		Name:         "merge",
+		Description:  "Merge the generated projects into the existing output file, keeping hand-written projects, workflows and top-level keys.",
+		Required:     false,
+		DefaultValue: "false",
+		Shorthand:    "",
+	},
+	{
+		Name:         "managed-project-prefix",
+		Description:  "In merge mode, also replace existing projects whose name starts with this prefix (projects marked with the generator comment are always replaced).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "output-format",
+		Description:  "Output format. [yaml|json|template|matrix].",
+		Required:     false,
+		DefaultValue: "yaml",
+		Shorthand:    "",
+	},
+	{
+		Name:         "template",
+		Description:  "Go text/template rendered against the generated config when output-format is template (i.e. {{ range .Projects }}{{ .Name }} {{ end }}).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "graph-format",
+		Description:  "Format of the graph command output. [dot|mermaid].",
+		Required:     false,
+		DefaultValue: "dot",
+		Shorthand:    "",
+	},
+	{
+		Name:         "header",
+		Description:  "Prefix the YAML output with a generated-file header holding the version, parameters and content hash.",
+		Required:     false,
+		DefaultValue: "false",
+		Shorthand:    "",
+	},
+	{
+		Name:         "force",
+		Description:  "Overwrite the output file even if it was edited since it was generated.",
+		Required:     false,
+		DefaultValue: "false",
+		Shorthand:    "",
+		PerRun:       true,
+	},
+	{
+		Name:         "yaml-anchors",
+		Description:  "Declare the autoplan and requirements blocks repeated by several projects once, as YAML anchors referenced with aliases.",
+		Required:     false,
+		DefaultValue: "false",
+		Shorthand:    "",
+	},
+	{
+		Name:         "output-file-mode",
+		Description:  "Octal permission mode of the output file.",
+		Required:     false,
+		DefaultValue: "0644",
+		Shorthand:    "",
+	},
+	{
+		Name:         "matrix-shards",
+		Description:  "When output-format is matrix, assign projects round-robin to this number of shards, recorded in a shard field (0 disables sharding).",
+		Required:     false,
+		DefaultValue: "0",
+		Shorthand:    "",
+	},
+	{
+		Name:         "report-file",
+		Description:  "Write a summary report of the generated and dropped projects to this file (github-step-summary appends it to the GitHub Actions job summary).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "report-format",
+		Description:  "Summary report format. [markdown|html].",
+		Required:     false,
+		DefaultValue: "markdown",
+		Shorthand:    "",
+	},
 	{
 		Name:         "workflow",
 		Description:  "Atlantis Workflow to be used.",
@@ -80,6 +191,27 @@ var ParameterList = []Parameter{
 		DefaultValue: "",
 		Shorthand:    "w",
 	},
+	{
+		Name:         "workflow-rules",
+		Description:  "Ordered rules to assign workflows by project dir, name or workspace, first match wins (i.e. dir:k8s/**=helm;name~^security-=policy-check). Unmatched projects use the workflow parameter.",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "project-name-template",
+		Description:  "Go text/template used to name projects (i.e. {{ .Dir | replace \"/\" \"_\" }}__{{ .Workspace }}). Defaults to path-with-dashes[-workspace].",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "name-collision-strategy",
+		Description:  "Strategy applied when several projects get the same name [fail|hash-suffix|index-suffix].",
+		Required:     false,
+		DefaultValue: "fail",
+		Shorthand:    "",
+	},
 	{
 		Name:         "when-modified",
 		Description:  "Atlantis will trigger an autoplan when these modifications occur (list of strings).",
@@ -87,6 +219,34 @@ var ParameterList = []Parameter{
 		DefaultValue: "**/*.tf,**/*.tfvars,**/*.json,**/*.tpl,**/*.tmpl,**/*.xml",
 		Shorthand:    "m",
 	},
+	{
+		Name:         "autoplan-rules",
+		Description:  "Ordered rules to enable or disable autoplan by project dir, name or workspace, first match wins (i.e. name:*-production=false;dir:legacy/**=false). Unmatched projects are autoplanned.",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "workspace-scoped-when-modified",
+		Description:  "In multi-workspace mode, only trigger an autoplan of a workspace when its own var file changes inside the pattern-detector folder.",
+		Required:     false,
+		DefaultValue: "true",
+		Shorthand:    "",
+	},
+	{
+		Name:         "validate",
+		Description:  "Validate the generated config against the Atlantis repo config spec before writing it.",
+		Required:     false,
+		DefaultValue: "false",
+		Shorthand:    "",
+	},
+	{
+		Name:         "server-side-workflows",
+		Description:  "Workflows defined in the Atlantis server side config, considered valid references when validating (list of strings).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
 	{
 		Name:         "excluded-projects",
 		Description:  "Atlantis regex filter to exclude projects.",
@@ -117,39 +277,164 @@ var ParameterList = []Parameter{
 	},
 	{
 		Name:         "pull-num",
-		Description:  "Github Pull Request Number to check diffs.",
+		Description:  "Pull Request Number to check diffs.",
 		Required:     false,
 		DefaultValue: "",
 		Shorthand:    "p",
+		PerRun:       true,
 	},
 	{
 		Name:         "base-repo-name",
-		Description:  "Github Repo Name.",
+		Description:  "Repo Name.",
 		Required:     false,
 		DefaultValue: "",
 		Shorthand:    "r",
 	},
 	{
 		Name:         "base-repo-owner",
-		Description:  "Github Repo Owner Name.",
+		Description:  "Repo Owner Name.",
 		Required:     false,
 		DefaultValue: "",
 		Shorthand:    "o",
 	},
+	{
+		Name:         "base-branch-name",
+		Description:  "Base branch of the PR, the git SCM diffs HEAD against its merge-base.",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+		PerRun:       true,
+	},
+	{
+		Name:         "changed-files",
+		Description:  "File listing the PR changed files for the file SCM, one path per line or git diff --name-status output.",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+		PerRun:       true,
+	},
+	{
+		Name:         "gh-hostname",
+		Description:  "GitHub hostname, set it for GitHub Enterprise Server (defaults to ATLANTIS_GH_HOSTNAME, then the origin remote host of the terraform base dir repo).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "gh-ca-bundle",
+		Description:  "PEM CA bundle file trusted for the GitHub API, on top of the system certificates.",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "gh-app-id",
+		Description:  "GitHub App ID, authenticates as the App instead of a token (defaults to ATLANTIS_GH_APP_ID).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "gh-app-installation-id",
+		Description:  "GitHub App installation ID, discovered from the repo when empty (defaults to ATLANTIS_GH_APP_INSTALLATION_ID).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "gh-app-key-file",
+		Description:  "GitHub App private key file (defaults to ATLANTIS_GH_APP_KEY_FILE, or the inline ATLANTIS_GH_APP_KEY).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
 	{
 		Name:         "gh-token",
 		Description:  "Specify the GitHub token when automatic detection is not possible.",
 		Required:     false,
 		DefaultValue: "",
 		Shorthand:    "t",
+		Secret:       true,
+	},
+	{
+		Name:         "scm",
+		Description:  "SCM used to get the PR changed files. [github|gitlab|bitbucket-cloud|bitbucket-datacenter|azuredevops|git|file].",
+		Required:     false,
+		DefaultValue: "github",
+		Shorthand:    "",
+	},
+	{
+		Name:         "azuredevops-hostname",
+		Description:  "Azure DevOps hostname or URL (defaults to ATLANTIS_AZUREDEVOPS_HOSTNAME, then dev.azure.com).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "azuredevops-token",
+		Description:  "Azure DevOps personal access token (defaults to ATLANTIS_AZUREDEVOPS_TOKEN).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+		Secret:       true,
+	},
+	{
+		Name:         "bitbucket-base-url",
+		Description:  "Bitbucket API URL (defaults to ATLANTIS_BITBUCKET_BASE_URL, then https://api.bitbucket.org for Bitbucket Cloud).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "bitbucket-user",
+		Description:  "Bitbucket user, the token is used as bearer token when empty (defaults to ATLANTIS_BITBUCKET_USER).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "bitbucket-token",
+		Description:  "Bitbucket app password or access token (defaults to ATLANTIS_BITBUCKET_TOKEN).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+		Secret:       true,
+	},
+	{
+		Name:         "gitlab-hostname",
+		Description:  "GitLab server hostname or URL (defaults to ATLANTIS_GITLAB_HOSTNAME, then gitlab.com).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "gitlab-project",
+		Description:  "GitLab project ID or path (defaults to base-repo-owner/base-repo-name).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+	},
+	{
+		Name:         "gitlab-token",
+		Description:  "GitLab token (defaults to ATLANTIS_GITLAB_TOKEN).",
+		Required:     false,
+		DefaultValue: "",
+		Shorthand:    "",
+		Secret:       true,
 	},
 	{
 		Name:        "pr-filter",
-		Description: "Filter projects based on the PR changes (Only for github SCM).",
+		Description: "Filter projects based on the PR changes.",
 		Required:    false,
 		Dependencies: DependentParameters{
 			WhenParentParameterIs: "true",
-			ParameterList:         []string{"pull-num", "base-repo-name", "base-repo-owner"}},
+			ParameterList:         []string{"pull-num", "base-repo-name", "base-repo-owner"},
+			// The git and file SCMs don't call a pull request API
+			SelectorParameter: "scm",
+			SelectorParameterLists: map[string][]string{
+				"git":  {"base-branch-name"},
+				"file": {"changed-files"},
+			}},
 		DefaultValue: "false",
 		Shorthand:    "u",
 	},
@@ -158,25 +443,27 @@ var ParameterList = []Parameter{
 // Init generates the config Parameters object and checks for missing required parameters
 func Init(ccmd *cobra.Command) (err error) {
 	GlobalConfig.Parameters = make(map[string]string)
+	GlobalConfig.ExplicitParameters = make(map[string]bool)
 	for i, param := range ParameterList {
-		GlobalConfig.Parameters[param.Name] = getFlagOrEnv(ccmd, ParameterList[i].Name,
-			generateEnvVarName(ParameterList[i].Name), ParameterList[i].DefaultValue)
+		GlobalConfig.Parameters[param.Name], GlobalConfig.ExplicitParameters[param.Name] = getFlagOrEnv(ccmd,
+			ParameterList[i].Name, generateEnvVarName(ParameterList[i].Name), ParameterList[i].DefaultValue)
 	}
 	err = CheckRequiredParameters(ParameterList)
 	return err
 }
 
-// GetFlagOrEnv gets the value of a flag or environment variable and if both are empty, returns the default value
-func getFlagOrEnv(ccmd *cobra.Command, flagName, envVar string, defaultValue string) string {
+// GetFlagOrEnv gets the value of a flag or environment variable and if both are empty, returns the default value.
+// It also reports whether the value was set explicitly rather than defaulted.
+func getFlagOrEnv(ccmd *cobra.Command, flagName, envVar string, defaultValue string) (string, bool) {
 	val, _ := ccmd.Flags().GetString(flagName)
 	if val != "" {
-		return val
+		return val, true
 	}
 	val = helpers.LookupEnvString(envVar)
 	if val != "" {
-		return val
+		return val, true
 	} else {
-		return defaultValue
+		return defaultValue, false
 	}
 }
 
@@ -187,14 +474,18 @@ func CheckRequiredParameters(parameterList []Parameter) error {
 	for _, param := range parameterList {
 		if param.Required && GlobalConfig.Parameters[param.Name] == "" {
 			missingParams = append(missingParams, param.Name)
-		} else if len(param.Dependencies.ParameterList) > 0 {
+		} else if len(param.Dependencies.ParameterList) > 0 || len(param.Dependencies.SelectorParameterLists) > 0 {
 			// Check if the parameter has dependent parameters
 			parentValue := GlobalConfig.Parameters[param.Name]
 			expectedParentValue := param.Dependencies.WhenParentParameterIs
 
 			if parentValue == expectedParentValue {
+				dependentParameters := param.Dependencies.ParameterList
+				if selected, found := param.Dependencies.SelectorParameterLists[GlobalConfig.Parameters[param.Dependencies.SelectorParameter]]; found {
+					dependentParameters = selected
+				}
 				// Iterate through the dependent parameters
-				for _, dependentParameter := range param.Dependencies.ParameterList {
+				for _, dependentParameter := range dependentParameters {
 					if GlobalConfig.Parameters[dependentParameter] == "" {
 						missingParams = append(missingParams, dependentParameter)
 					}
@@ -39,6 +39,19 @@ func TestInit(t *testing.T) {
 	assert.NoError(t, err, "Expected no error, but got: %v", err)
 }
 
+func TestInitExplicitParameters(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("workflow", "", "")
+	assert.NoError(t, cmd.Flags().Set("workflow", "custom"))
+	t.Setenv("AUTOMERGE", "true")
+	t.Setenv("DISCOVERY_MODE", "")
+	assert.NoError(t, Init(cmd))
+	assert.True(t, GlobalConfig.ExplicitParameters["workflow"])
+	assert.True(t, GlobalConfig.ExplicitParameters["automerge"])
+	assert.False(t, GlobalConfig.ExplicitParameters["discovery-mode"])
+	assert.Equal(t, "single-workspace", GlobalConfig.Parameters["discovery-mode"])
+}
+
 func TestGenerateDescription(t *testing.T) {
 	testCases := []struct {
 		param       string
@@ -76,6 +89,7 @@ func TestCheckRequiredParameters(t *testing.T) {
 
 	tests := []struct {
 		name       string
+		scm        string
 		parameters []Parameter
 		expected   error
 	}{
@@ -133,10 +147,27 @@ func TestCheckRequiredParameters(t *testing.T) {
 			},
 			expected: fmt.Errorf("Missing required parameters: mydep"),
 		},
+		{
+			name: "Dependent parameters selected by another parameter",
+			scm:  "git",
+			parameters: []Parameter{
+				{
+					Name: "pr-filter",
+					Dependencies: DependentParameters{
+						WhenParentParameterIs:  "true",
+						ParameterList:          []string{"pull-num"},
+						SelectorParameter:      "scm",
+						SelectorParameterLists: map[string][]string{"git": {"base-branch-name"}},
+					},
+				},
+			},
+			expected: fmt.Errorf("Missing required parameters: base-branch-name"),
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
+			GlobalConfig.Parameters["scm"] = test.scm
 			err := CheckRequiredParameters(test.parameters)
 			if err != nil {
 				if err.Error() != test.expected.Error() {
@@ -148,3 +179,22 @@ func TestCheckRequiredParameters(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckRequiredParametersPRFilter(t *testing.T) {
+	// The git SCM only needs the base branch
+	GlobalConfig.Parameters = map[string]string{"pr-filter": "true", "scm": "git"}
+	assert.EqualError(t, CheckRequiredParameters(ParameterList), "Missing required parameters: base-branch-name")
+	GlobalConfig.Parameters["base-branch-name"] = "master"
+	assert.NoError(t, CheckRequiredParameters(ParameterList))
+
+	// The file SCM only needs the changed files
+	GlobalConfig.Parameters = map[string]string{"pr-filter": "true", "scm": "file"}
+	assert.EqualError(t, CheckRequiredParameters(ParameterList), "Missing required parameters: changed-files")
+	GlobalConfig.Parameters["changed-files"] = "changes.txt"
+	assert.NoError(t, CheckRequiredParameters(ParameterList))
+
+	// The API SCMs need the pull request
+	GlobalConfig.Parameters = map[string]string{"pr-filter": "true", "scm": "github"}
+	assert.EqualError(t, CheckRequiredParameters(ParameterList),
+		"Missing required parameters: pull-num, base-repo-name, base-repo-owner")
+}
@@ -0,0 +1,137 @@
+package gitdiff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+// runGitDiff returns the list of files changed on HEAD since it diverged from the base ref,
+// comparing the merge-base of both with HEAD like a pull request does.
+func runGitDiff(repoPath, baseRef string) ([]scm.ChangedFile, error) {
+	var changedFiles []scm.ChangedFile
+	headCommit, mergeBase, err := resolveMergeBase(repoPath, baseRef)
+	if err != nil {
+		return nil, err
+	}
+
+	baseTree, err := mergeBase.Tree()
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTreeWithOptions(context.Background(), baseTree, headTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		switch {
+		case change.From.Name == "":
+			changedFiles = append(changedFiles, scm.ChangedFile{Path: change.To.Name, Status: scm.StatusAdded})
+		case change.To.Name == "":
+			changedFiles = append(changedFiles, scm.ChangedFile{Path: change.From.Name, Status: scm.StatusRemoved})
+		case change.From.Name != change.To.Name:
+			changedFiles = append(changedFiles, scm.ChangedFile{Path: change.To.Name, Status: scm.StatusRenamed, PreviousPath: change.From.Name})
+		default:
+			changedFiles = append(changedFiles, scm.ChangedFile{Path: change.To.Name, Status: scm.StatusModified})
+		}
+	}
+	return changedFiles, nil
+}
+
+// resolveMergeBase opens the repository holding the repo path and returns its HEAD commit
+// and the merge-base of HEAD and the base ref.
+func resolveMergeBase(repoPath, baseRef string) (headCommit, mergeBase *object.Commit, err error) {
+	repo, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, nil, err
+	}
+	headCommit, err = repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, nil, err
+	}
+	baseCommit, err := resolveCommit(repo, baseRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	mergeBases, err := headCommit.MergeBase(baseCommit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(mergeBases) == 0 {
+		return nil, nil, fmt.Errorf("HEAD and %s have no common ancestor, is the clone shallow?", baseRef)
+	}
+	return headCommit, mergeBases[0], nil
+}
+
+// resolveCommit resolves the base ref, falling back to the origin remote branch
+// as the base branch is usually not checked out locally.
+func resolveCommit(repo *git.Repository, ref string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		hash, err = repo.ResolveRevision(plumbing.Revision("refs/remotes/origin/" + ref))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolving base ref %s: %w", ref, err)
+	}
+	return repo.CommitObject(*hash)
+}
+
+// GetChangedFiles returns the files changed between the base branch and HEAD of the
+// repository holding the terraform base dir, which is looked up from the base dir upwards,
+// so the tool can run from a subfolder of the repository. No token nor API call is needed.
+func GetChangedFiles() (ChangedFiles []scm.ChangedFile, err error) {
+	baseRef := config.GlobalConfig.Parameters["base-branch-name"]
+	if baseRef == "" {
+		err = errors.New("base-branch-name is not set.\n" +
+			"Please use base-branch-name parameter or BASE_BRANCH_NAME environment variable to set the base ref.")
+		return ChangedFiles, err
+	}
+	prChangedFiles, err := runGitDiff(config.GlobalConfig.Parameters["terraform-base-dir"], baseRef)
+	if err != nil {
+		return []scm.ChangedFile{}, err
+	}
+	return prChangedFiles, err
+}
+
+// GetMergeBaseFiles returns which of the paths were files on the merge-base of HEAD and the
+// base branch, that is before the changes of the pull request. Paths are relative to the root
+// of the repository holding the terraform base dir.
+func GetMergeBaseFiles(paths []string) (map[string]bool, error) {
+	baseRef := config.GlobalConfig.Parameters["base-branch-name"]
+	if baseRef == "" {
+		return nil, errors.New("base-branch-name is not set.\n" +
+			"Please use base-branch-name parameter or BASE_BRANCH_NAME environment variable to set the base ref.")
+	}
+	_, mergeBase, err := resolveMergeBase(config.GlobalConfig.Parameters["terraform-base-dir"], baseRef)
+	if err != nil {
+		return nil, err
+	}
+	baseTree, err := mergeBase.Tree()
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]bool{}
+	for _, filePath := range paths {
+		_, err := baseTree.File(filePath)
+		if err == nil {
+			files[filePath] = true
+		} else if !errors.Is(err, object.ErrFileNotFound) {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,131 @@
+package gitdiff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+// commitFiles writes and removes files in the worktree and commits them.
+func commitFiles(t *testing.T, repoPath string, worktree *git.Worktree, files map[string]string, removed ...string) {
+	for name, content := range files {
+		filePath := filepath.Join(repoPath, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		assert.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+		_, err := worktree.Add(name)
+		assert.NoError(t, err)
+	}
+	for _, name := range removed {
+		_, err := worktree.Remove(name)
+		assert.NoError(t, err)
+	}
+	_, err := worktree.Commit("test", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+}
+
+func newTestRepo(t *testing.T) string {
+	repoPath := t.TempDir()
+	repo, err := git.PlainInit(repoPath, false)
+	assert.NoError(t, err)
+	worktree, err := repo.Worktree()
+	assert.NoError(t, err)
+	commitFiles(t, repoPath, worktree, map[string]string{
+		"app/main.tf":     "app",
+		"network/main.tf": "network",
+		"old/main.tf":     "old",
+	})
+	head, err := repo.Head()
+	assert.NoError(t, err)
+	baseBranch := head.Name()
+
+	// The feature branch changes, renames and removes files
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true})
+	assert.NoError(t, err)
+	commitFiles(t, repoPath, worktree, map[string]string{
+		"app/main.tf": "app changed",
+		"db/main.tf":  "old",
+	}, "old/main.tf", "network/main.tf")
+
+	// Changes on the base branch after the merge-base are not part of the diff
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: baseBranch})
+	assert.NoError(t, err)
+	commitFiles(t, repoPath, worktree, map[string]string{"base/main.tf": "base"})
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature")})
+	assert.NoError(t, err)
+	return repoPath
+}
+
+func TestRunGitDiff(t *testing.T) {
+	repoPath := newTestRepo(t)
+
+	changedFiles, err := runGitDiff(repoPath, "master")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "db/main.tf", Status: scm.StatusRenamed, PreviousPath: "old/main.tf"},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+	}, changedFiles)
+
+	// The repository is found from a subfolder
+	changedFiles, err = runGitDiff(filepath.Join(repoPath, "app"), "master")
+	assert.NoError(t, err)
+	assert.Len(t, changedFiles, 3)
+
+	_, err = runGitDiff(repoPath, "missing")
+	assert.Error(t, err)
+
+	_, err = runGitDiff(t.TempDir(), "master")
+	assert.Error(t, err)
+}
+
+func TestGetChangedFiles(t *testing.T) {
+	repoPath := newTestRepo(t)
+
+	// The repository is found from the terraform base dir, not the current directory
+	config.GlobalConfig.Parameters = map[string]string{
+		"base-branch-name":   "master",
+		"terraform-base-dir": filepath.Join(repoPath, "app"),
+	}
+	changedFiles, err := GetChangedFiles()
+	assert.NoError(t, err)
+	assert.Len(t, changedFiles, 3)
+
+	config.GlobalConfig.Parameters["terraform-base-dir"] = t.TempDir()
+	_, err = GetChangedFiles()
+	assert.Error(t, err)
+
+	config.GlobalConfig.Parameters["base-branch-name"] = ""
+	_, err = GetChangedFiles()
+	assert.Error(t, err)
+}
+
+func TestGetMergeBaseFiles(t *testing.T) {
+	repoPath := newTestRepo(t)
+	config.GlobalConfig.Parameters = map[string]string{
+		"base-branch-name":   "master",
+		"terraform-base-dir": repoPath,
+	}
+
+	// Files removed by the feature branch are on the merge-base, files of the base branch added since are not
+	files, err := GetMergeBaseFiles([]string{"old/main.tf", "network/main.tf", "db/main.tf", "base/main.tf", "app"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"old/main.tf": true, "network/main.tf": true}, files)
+
+	config.GlobalConfig.Parameters["base-branch-name"] = "missing"
+	_, err = GetMergeBaseFiles([]string{"old/main.tf"})
+	assert.Error(t, err)
+
+	config.GlobalConfig.Parameters["base-branch-name"] = ""
+	_, err = GetMergeBaseFiles([]string{"old/main.tf"})
+	assert.Error(t, err)
+}
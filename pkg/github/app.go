@@ -0,0 +1,106 @@
+package github
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/go-github/github"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+)
+
+// appJWT returns the JWT authenticating as the GitHub App. GitHub accepts JWTs valid
+// for up to 10 minutes, it is issued a minute in the past to allow for clock drift.
+func appJWT(appID string, privateKey *rsa.PrivateKey, now time.Time) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    appID,
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(privateKey)
+}
+
+// appInstallationToken mints an installation access token with a client authenticated
+// as the GitHub App. The installation is discovered from the repo when its ID is not set.
+func appInstallationToken(client *github.Client, installationID, owner, repo string) (string, error) {
+	ctx := context.Background()
+	var id int64
+	if installationID != "" {
+		var err error
+		id, err = strconv.ParseInt(installationID, 10, 64)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		installation, _, err := client.Apps.FindRepositoryInstallation(ctx, owner, repo)
+		if err != nil {
+			return "", err
+		}
+		id = installation.GetID()
+	}
+	// go-github CreateInstallationToken still targets the removed installations/{id}/access_tokens endpoint
+	req, err := client.NewRequest(http.MethodPost, fmt.Sprintf("app/installations/%d/access_tokens", id), nil)
+	if err != nil {
+		return "", err
+	}
+	var token github.InstallationToken
+	_, err = client.Do(ctx, req, &token)
+	if err != nil {
+		return "", err
+	}
+	return token.GetToken(), nil
+}
+
+// getAppToken returns an installation token when a GitHub App is configured, or an
+// empty token otherwise. Without App parameters, the settings of the Atlantis server are
+// used when they are complete, or the static token is used instead.
+func getAppToken(hostname, caBundle, owner, repo string) (string, error) {
+	appID := config.GlobalConfig.Parameters["gh-app-id"]
+	keyFile := config.GlobalConfig.Parameters["gh-app-key-file"]
+	var key string
+	if appID == "" && keyFile == "" {
+		appID = helpers.LookupEnvString("ATLANTIS_GH_APP_ID")
+		keyFile = helpers.LookupEnvString("ATLANTIS_GH_APP_KEY_FILE")
+		key = helpers.LookupEnvString("ATLANTIS_GH_APP_KEY")
+		if appID == "" || (keyFile == "" && key == "") {
+			return "", nil
+		}
+	} else if appID == "" || keyFile == "" {
+		return "", errors.New("gh-app-id and gh-app-key-file must be set together.\n" +
+			"Please use gh-app-id and gh-app-key-file parameters or GH_APP_ID and GH_APP_KEY_FILE environment variables to set the GitHub App.")
+	}
+	installationID := config.GlobalConfig.Parameters["gh-app-installation-id"]
+	if installationID == "" {
+		installationID = helpers.LookupEnvString("ATLANTIS_GH_APP_INSTALLATION_ID")
+	}
+
+	// The key file takes precedence over the inline key, like on the Atlantis server
+	pem := []byte(key)
+	if keyFile != "" {
+		var err error
+		pem, err = os.ReadFile(keyFile)
+		if err != nil {
+			return "", err
+		}
+	}
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+	if err != nil {
+		return "", err
+	}
+	appToken, err := appJWT(appID, privateKey, time.Now())
+	if err != nil {
+		return "", err
+	}
+	client, err := newGitHubClient(appToken, hostname, caBundle)
+	if err != nil {
+		return "", err
+	}
+	return appInstallationToken(client, installationID, owner, repo)
+}
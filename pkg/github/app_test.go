@@ -0,0 +1,136 @@
+package github
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+)
+
+func writeAppKey(t *testing.T) (string, *rsa.PrivateKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "app.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	assert.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	return keyFile, privateKey
+}
+
+// newAppServer serves the GitHub App endpoints, checking the requests are signed with the App key.
+func newAppServer(t *testing.T, publicKey *rsa.PublicKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := jwt.ParseWithClaims(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+			&jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) { return publicKey, nil },
+			jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer("123"))
+		if err != nil || !token.Valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v3/repos/owner/repo/installation":
+			fmt.Fprint(w, `{"id": 42}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v3/app/installations/42/access_tokens":
+			fmt.Fprint(w, `{"token": "ghs_installation", "expires_at": "2030-01-01T00:00:00Z"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestAppJWT(t *testing.T) {
+	_, privateKey := writeAppKey(t)
+	now := time.Now()
+	signedToken, err := appJWT("123", privateKey, now)
+	assert.NoError(t, err)
+
+	claims := &jwt.RegisteredClaims{}
+	_, err = jwt.ParseWithClaims(signedToken, claims,
+		func(token *jwt.Token) (interface{}, error) { return &privateKey.PublicKey, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, "123", claims.Issuer)
+	assert.Equal(t, now.Add(-time.Minute).Unix(), claims.IssuedAt.Unix())
+	assert.Equal(t, now.Add(9*time.Minute).Unix(), claims.ExpiresAt.Unix())
+}
+
+func TestGetAppToken(t *testing.T) {
+	keyFile, privateKey := writeAppKey(t)
+	server := newAppServer(t, &privateKey.PublicKey)
+	defer server.Close()
+
+	// No App configured
+	t.Setenv("ATLANTIS_GH_APP_ID", "")
+	t.Setenv("ATLANTIS_GH_APP_KEY_FILE", "")
+	t.Setenv("ATLANTIS_GH_APP_KEY", "")
+	t.Setenv("ATLANTIS_GH_APP_INSTALLATION_ID", "")
+	config.GlobalConfig.Parameters = map[string]string{}
+	token, err := getAppToken(server.URL, "", "owner", "repo")
+	assert.NoError(t, err)
+	assert.Empty(t, token)
+
+	// Incomplete Atlantis settings fall back to the static token
+	t.Setenv("ATLANTIS_GH_APP_ID", "123")
+	token, err = getAppToken(server.URL, "", "owner", "repo")
+	assert.NoError(t, err)
+	assert.Empty(t, token)
+
+	// The installation is discovered from the repo, settings fall back to the Atlantis ones
+	t.Setenv("ATLANTIS_GH_APP_KEY_FILE", keyFile)
+	token, err = getAppToken(server.URL, "", "owner", "repo")
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_installation", token)
+
+	// The Atlantis inline key is supported too
+	keyPEM, err := os.ReadFile(keyFile)
+	assert.NoError(t, err)
+	t.Setenv("ATLANTIS_GH_APP_KEY_FILE", "")
+	t.Setenv("ATLANTIS_GH_APP_KEY", string(keyPEM))
+	token, err = getAppToken(server.URL, "", "owner", "repo")
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_installation", token)
+
+	_, err = getAppToken(server.URL, "", "owner", "missing")
+	assert.Error(t, err)
+
+	config.GlobalConfig.Parameters["gh-app-installation-id"] = "42"
+	token, err = getAppToken(server.URL, "", "owner", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_installation", token)
+
+	config.GlobalConfig.Parameters["gh-app-installation-id"] = "forty-two"
+	_, err = getAppToken(server.URL, "", "owner", "repo")
+	assert.Error(t, err)
+
+	// Incomplete App parameters are reported
+	config.GlobalConfig.Parameters["gh-app-installation-id"] = ""
+	config.GlobalConfig.Parameters["gh-app-id"] = "123"
+	_, err = getAppToken(server.URL, "", "owner", "repo")
+	assert.Error(t, err)
+
+	config.GlobalConfig.Parameters["gh-app-key-file"] = keyFile
+	token, err = getAppToken(server.URL, "", "owner", "repo")
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_installation", token)
+
+	// Requests signed by another App are rejected
+	config.GlobalConfig.Parameters["gh-app-id"] = "456"
+	_, err = getAppToken(server.URL, "", "owner", "repo")
+	assert.Error(t, err)
+
+	invalidKeyFile := filepath.Join(t.TempDir(), "invalid.pem")
+	assert.NoError(t, os.WriteFile(invalidKeyFile, []byte("not a key"), 0600))
+	config.GlobalConfig.Parameters["gh-app-key-file"] = invalidKeyFile
+	_, err = getAppToken(server.URL, "", "owner", "repo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package github
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+)
+
+const defaultHostname = "github.com"
+
+// enterpriseURLs returns the API and upload URLs of a GitHub Enterprise Server.
+// The hostname may include a scheme, and an API path when it is not the default /api/v3.
+func enterpriseURLs(hostname string) (baseURL, uploadURL string, err error) {
+	if !strings.Contains(hostname, "://") {
+		hostname = "https://" + hostname
+	}
+	serverURL, err := url.Parse(hostname)
+	if err != nil {
+		return "", "", err
+	}
+	if serverURL.Host == "" {
+		return "", "", fmt.Errorf("invalid GitHub hostname '%s'", hostname)
+	}
+	apiPath := strings.TrimSuffix(serverURL.Path, "/")
+	if apiPath == "" {
+		apiPath = "/api/v3"
+	}
+	serverURL.Path = apiPath + "/"
+	baseURL = serverURL.String()
+	serverURL.Path = strings.TrimSuffix(apiPath, "/v3") + "/uploads/"
+	uploadURL = serverURL.String()
+	return baseURL, uploadURL, nil
+}
+
+// hostname returns the GitHub server hostname. It defaults to the one of the Atlantis
+// server, then to the host of the clone URL of the repo holding the terraform base dir,
+// so GHES works without configuration.
+func hostname() string {
+	if hostname := config.GlobalConfig.Parameters["gh-hostname"]; hostname != "" {
+		return hostname
+	}
+	if hostname := helpers.LookupEnvString("ATLANTIS_GH_HOSTNAME"); hostname != "" {
+		return hostname
+	}
+	if hostname := cloneURLHostname(originURL(config.GlobalConfig.Parameters["terraform-base-dir"])); hostname != "" {
+		return hostname
+	}
+	return defaultHostname
+}
+
+// originURL returns the URL of the origin remote of the repository checked out in the path.
+func originURL(repoPath string) string {
+	repo, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return ""
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return remote.Config().URLs[0]
+}
+
+// cloneURLHostname returns the host of an HTTPS or SSH clone URL, without credentials.
+// The port of SSH URLs is the one of the SSH server, not of the API, so it is dropped.
+func cloneURLHostname(cloneURL string) string {
+	if cloneURL == "" {
+		return ""
+	}
+	if strings.Contains(cloneURL, "://") {
+		parsedURL, err := url.Parse(cloneURL)
+		if err != nil {
+			return ""
+		}
+		if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+			return parsedURL.Hostname()
+		}
+		return parsedURL.Host
+	}
+	// scp-like syntax, i.e. git@github.example.com:owner/repo.git
+	host, _, found := strings.Cut(cloneURL, ":")
+	if !found {
+		return ""
+	}
+	if _, afterUser, found := strings.Cut(host, "@"); found {
+		host = afterUser
+	}
+	return host
+}
+
+// newHTTPClient returns the HTTP client of the GitHub API, trusting the certificates
+// of the CA bundle on top of the system ones when it is set.
+func newHTTPClient(caBundle string) (*http.Client, error) {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if caBundle == "" {
+		return httpClient, nil
+	}
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, err
+	}
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in CA bundle '%s'", caBundle)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	httpClient.Transport = transport
+	return httpClient, nil
+}
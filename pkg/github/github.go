@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"os"
 	"os/user"
 	"path/filepath"
 	"strconv"
@@ -11,10 +13,25 @@ import (
 
 	"github.com/google/go-github/github"
 	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/gitdiff"
 	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
 	"golang.org/x/oauth2"
 )
 
+// maxListedFiles is the maximum number of files GitHub lists for a pull request.
+const maxListedFiles = 3000
+
+var errFileLimit = fmt.Errorf("pull request file list is truncated to %d files", maxListedFiles)
+
+// pullRequestFile is a changed file of a pull request. The go-github CommitFile type
+// lacks the previous filename of renamed files.
+type pullRequestFile struct {
+	Filename         string `json:"filename"`
+	Status           string `json:"status"`
+	PreviousFilename string `json:"previous_filename"`
+}
+
 type GithubRequest struct {
 	AuthToken         string
 	Owner             string
@@ -23,52 +40,139 @@ type GithubRequest struct {
 }
 
 // NewGitHubClient creates a new GitHub client with the provided auth token.
-func newGitHubClient(authToken string) *github.Client {
-	ctx := context.Background()
+// Hostnames other than github.com target a GitHub Enterprise Server.
+func newGitHubClient(authToken, hostname, caBundle string) (*github.Client, error) {
+	tc, err := newOAuthClient(authToken, caBundle)
+	if err != nil {
+		return nil, err
+	}
+	if hostname == "" || hostname == defaultHostname {
+		return github.NewClient(tc), nil
+	}
+	baseURL, uploadURL, err := enterpriseURLs(hostname)
+	if err != nil {
+		return nil, err
+	}
+	return github.NewEnterpriseClient(baseURL, uploadURL, tc)
+}
+
+// newOAuthClient returns the HTTP client of the GitHub API authenticated with the token.
+func newOAuthClient(authToken, caBundle string) (*http.Client, error) {
+	httpClient, err := newHTTPClient(caBundle)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: authToken},
 	)
 	tc := oauth2.NewClient(ctx, ts)
-	return github.NewClient(tc)
+	// The oauth2 client wraps the transport of the HTTP client, but not its timeout
+	tc.Timeout = httpClient.Timeout
+	return tc, nil
 }
 
 // runGHRequest returns a list of changed files in a pull request.
-func runGHRequest(authToken, owner, repo, pullReqNum string) ([]string, error) {
-	var changedFiles []string
+// All the pages are listed, errFileLimit is returned when GitHub truncates the list.
+func runGHRequest(client *github.Client, owner, repo, pullReqNum string) ([]scm.ChangedFile, error) {
+	var changedFiles []scm.ChangedFile
 	prNum, err := strconv.Atoi(pullReqNum)
 	if err != nil {
 		return nil, err
 	}
-	client := newGitHubClient(authToken)
-	files, _, err := client.PullRequests.ListFiles(context.Background(), owner, repo, prNum, nil)
+	page := 1
+	for page != 0 {
+		req, err := client.NewRequest(http.MethodGet,
+			fmt.Sprintf("repos/%s/%s/pulls/%d/files?per_page=100&page=%d", owner, repo, prNum, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		var files []pullRequestFile
+		resp, err := client.Do(context.Background(), req, &files)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			changedFile := scm.ChangedFile{Path: file.Filename, Status: changeStatus(file.Status)}
+			if changedFile.Status == scm.StatusRenamed {
+				changedFile.PreviousPath = file.PreviousFilename
+			}
+			changedFiles = append(changedFiles, changedFile)
+		}
+		page = resp.NextPage
+	}
+	if len(changedFiles) >= maxListedFiles {
+		return changedFiles, errFileLimit
+	}
+	return changedFiles, nil
+}
+
+// listChangedFiles returns the changed files of a pull request. Pull requests beyond the
+// GitHub file limit fall back to the local git diff, a truncated list would silently drop projects.
+func listChangedFiles(client *github.Client, owner, repo, pullReqNum string) ([]scm.ChangedFile, error) {
+	changedFiles, err := runGHRequest(client, owner, repo, pullReqNum)
+	if !errors.Is(err, errFileLimit) {
+		return changedFiles, err
+	}
+	fmt.Fprintf(os.Stderr, "WARNING: pull request #%s changes %d files or more, GitHub does not list them all. "+
+		"Falling back to the local git diff.\n", pullReqNum, maxListedFiles)
+	changedFiles, err = gitdiff.GetChangedFiles()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("pull request #%s changes %d files or more and the local git diff fallback failed: %w",
+			pullReqNum, maxListedFiles, err)
 	}
-	for _, file := range files {
-		changedFiles = append(changedFiles, *file.Filename)
+	return changedFiles, nil
+}
+
+// changeStatus normalizes the status of a pull request file.
+func changeStatus(status string) string {
+	switch status {
+	case "added", "copied":
+		return scm.StatusAdded
+	case "removed":
+		return scm.StatusRemoved
+	case "renamed":
+		return scm.StatusRenamed
+	default:
+		return scm.StatusModified
 	}
-	return changedFiles, err
 }
 
 // GetChangedFiles gets the parameters to call a ghrequest that returns a list of changed files.
-func GetChangedFiles() (ChangedFiles []string, err error) {
-	// Parse the token from the git config file
-	token, _ := getTokenFromGitCredentialsFile()
+// A configured GitHub App takes precedence over the static tokens.
+func GetChangedFiles() (ChangedFiles []scm.ChangedFile, err error) {
+	host := hostname()
+	caBundle := config.GlobalConfig.Parameters["gh-ca-bundle"]
+	owner := config.GlobalConfig.Parameters["base-repo-owner"]
+	repo := config.GlobalConfig.Parameters["base-repo-name"]
+
+	token, err := getAppToken(host, caBundle, owner, repo)
+	if err != nil {
+		return ChangedFiles, fmt.Errorf("authenticating as GitHub App: %w", err)
+	}
+	if token == "" {
+		// Parse the token from the git config file
+		token, _ = getTokenFromGitCredentialsFile()
+	}
 	if token == "" {
 		token = config.GlobalConfig.Parameters["gh-token"]
 	}
 	if token == "" {
 		err = errors.New("gh-token could not be parsed from .git/config file.\n" +
-			"Please use gh-token parameter or GH_TOKEN environment variable to set the token.")
+			"Please use gh-token parameter or GH_TOKEN environment variable to set the token, or gh-app-id to authenticate as a GitHub App.")
+		return ChangedFiles, err
+	}
+	client, err := newGitHubClient(token, host, caBundle)
+	if err != nil {
 		return ChangedFiles, err
 	}
-	prChangedFiles, err := runGHRequest(
-		token,
-		config.GlobalConfig.Parameters["base-repo-owner"],
-		config.GlobalConfig.Parameters["base-repo-name"],
+	prChangedFiles, err := listChangedFiles(
+		client,
+		owner,
+		repo,
 		config.GlobalConfig.Parameters["pull-num"])
 	if err != nil {
-		return []string{}, err
+		return []scm.ChangedFile{}, err
 	}
 	return prChangedFiles, err
 }
@@ -0,0 +1,126 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+const defaultHostname = "gitlab.com"
+
+// MergeRequestDiff is a changed file of a merge request, as returned by the diffs API.
+type MergeRequestDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// runGLRequest returns the list of changed files in a merge request.
+// The diffs API is paginated, pages are followed through the X-Next-Page header.
+func runGLRequest(baseURL, authToken, project, mergeRequestIID string) ([]scm.ChangedFile, error) {
+	var changedFiles []scm.ChangedFile
+	if _, err := strconv.Atoi(mergeRequestIID); err != nil {
+		return nil, err
+	}
+	page := "1"
+	for page != "" {
+		requestURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%s/diffs?per_page=100&page=%s",
+			baseURL, url.PathEscape(project), mergeRequestIID, page)
+		req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("PRIVATE-TOKEN", authToken)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: unexpected status %s", requestURL, resp.Status)
+		}
+		var diffs []MergeRequestDiff
+		err = json.NewDecoder(resp.Body).Decode(&diffs)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, diff := range diffs {
+			changedFiles = append(changedFiles, diff.changedFile())
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return changedFiles, nil
+}
+
+// changedFile returns the normalized changed file of a merge request diff.
+func (diff MergeRequestDiff) changedFile() scm.ChangedFile {
+	switch {
+	case diff.NewFile:
+		return scm.ChangedFile{Path: diff.NewPath, Status: scm.StatusAdded}
+	case diff.DeletedFile:
+		return scm.ChangedFile{Path: diff.OldPath, Status: scm.StatusRemoved}
+	case diff.RenamedFile:
+		return scm.ChangedFile{Path: diff.NewPath, Status: scm.StatusRenamed, PreviousPath: diff.OldPath}
+	default:
+		return scm.ChangedFile{Path: diff.NewPath, Status: scm.StatusModified}
+	}
+}
+
+// GetChangedFiles gets the parameters to call a glrequest that returns a list of changed files.
+// The GitLab server settings fall back to the ones of the Atlantis server.
+func GetChangedFiles() (ChangedFiles []scm.ChangedFile, err error) {
+	token := config.GlobalConfig.Parameters["gitlab-token"]
+	if token == "" {
+		token = helpers.LookupEnvString("ATLANTIS_GITLAB_TOKEN")
+	}
+	if token == "" {
+		err = errors.New("gitlab-token is not set.\n" +
+			"Please use gitlab-token parameter or GITLAB_TOKEN environment variable to set the token.")
+		return ChangedFiles, err
+	}
+	hostname := config.GlobalConfig.Parameters["gitlab-hostname"]
+	if hostname == "" {
+		hostname = helpers.LookupEnvString("ATLANTIS_GITLAB_HOSTNAME")
+	}
+	// The project can be set by ID or path, it defaults to the base repo path
+	project := config.GlobalConfig.Parameters["gitlab-project"]
+	if project == "" {
+		project = fmt.Sprintf("%s/%s",
+			config.GlobalConfig.Parameters["base-repo-owner"],
+			config.GlobalConfig.Parameters["base-repo-name"])
+	}
+	mrChangedFiles, err := runGLRequest(
+		baseURL(hostname),
+		token,
+		project,
+		config.GlobalConfig.Parameters["pull-num"])
+	if err != nil {
+		return []scm.ChangedFile{}, err
+	}
+	return mrChangedFiles, err
+}
+
+// baseURL returns the GitLab server URL, the hostname may include a scheme like Atlantis allows.
+func baseURL(hostname string) string {
+	if hostname == "" {
+		hostname = defaultHostname
+	}
+	if !strings.Contains(hostname, "://") {
+		hostname = "https://" + hostname
+	}
+	return strings.TrimSuffix(hostname, "/")
+}
@@ -0,0 +1,92 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/config"
+	"github.com/totmicro/atlantis-yaml-generator/pkg/scm"
+)
+
+func newGitLabServer(t *testing.T) *httptest.Server {
+	pages := map[string]string{
+		"1": `[{"old_path": "app/main.tf", "new_path": "app/main.tf"},
+		       {"old_path": "old/vars.tf", "new_path": "db/vars.tf", "renamed_file": true}]`,
+		"2": `[{"old_path": "network/main.tf", "new_path": "network/main.tf", "deleted_file": true}]`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Project paths are sent URL encoded
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fsubgroup%2Frepo/merge_requests/7/diffs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		if page == "1" {
+			w.Header().Set("X-Next-Page", "2")
+		}
+		fmt.Fprint(w, pages[page])
+	}))
+}
+
+func TestRunGLRequest(t *testing.T) {
+	server := newGitLabServer(t)
+	defer server.Close()
+
+	changedFiles, err := runGLRequest(server.URL, "test-token", "group/subgroup/repo", "7")
+	assert.NoError(t, err)
+	assert.Equal(t, []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "db/vars.tf", Status: scm.StatusRenamed, PreviousPath: "old/vars.tf"},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+	}, changedFiles)
+
+	_, err = runGLRequest(server.URL, "wrong-token", "group/subgroup/repo", "7")
+	assert.Error(t, err)
+
+	_, err = runGLRequest(server.URL, "test-token", "group/subgroup/repo", "seven")
+	assert.Error(t, err)
+}
+
+func TestGetChangedFiles(t *testing.T) {
+	server := newGitLabServer(t)
+	defer server.Close()
+
+	// Server settings fall back to the Atlantis ones
+	t.Setenv("ATLANTIS_GITLAB_HOSTNAME", server.URL)
+	t.Setenv("ATLANTIS_GITLAB_TOKEN", "test-token")
+	config.GlobalConfig.Parameters = map[string]string{
+		"base-repo-owner": "group/subgroup",
+		"base-repo-name":  "repo",
+		"pull-num":        "7",
+	}
+	changedFiles, err := GetChangedFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []scm.ChangedFile{
+		{Path: "app/main.tf", Status: scm.StatusModified},
+		{Path: "db/vars.tf", Status: scm.StatusRenamed, PreviousPath: "old/vars.tf"},
+		{Path: "network/main.tf", Status: scm.StatusRemoved},
+	}, changedFiles)
+
+	// Parameters take precedence over the Atlantis settings
+	config.GlobalConfig.Parameters["gitlab-token"] = "wrong-token"
+	_, err = GetChangedFiles()
+	assert.Error(t, err)
+
+	t.Setenv("ATLANTIS_GITLAB_TOKEN", "")
+	config.GlobalConfig.Parameters["gitlab-token"] = ""
+	_, err = GetChangedFiles()
+	assert.Error(t, err)
+}
+
+func TestBaseURL(t *testing.T) {
+	assert.Equal(t, "https://gitlab.com", baseURL(""))
+	assert.Equal(t, "https://gitlab.example.com", baseURL("gitlab.example.com"))
+	assert.Equal(t, "http://localhost:8080", baseURL("http://localhost:8080/"))
+}
@@ -1,8 +1,11 @@
 package helpers
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -13,17 +16,98 @@ func LookupEnvString(key string) string {
 	return ""
 }
 
+// DefaultFileMode is the permission of the files written by WriteFile.
+const DefaultFileMode os.FileMode = 0644
+
 func WriteFile(content, filePath string) error {
-	// Open the file for writing, creating it if it doesn't exist
-	file, err := os.Create(filePath)
+	return WriteFileWithMode(content, filePath, DefaultFileMode)
+}
+
+// WriteFileWithMode atomically replaces the file content: it is written to a temporary file
+// in the same directory, synced and renamed over the target, so readers never see a partial file.
+// Missing parent directories are created, and the write is skipped when the content is unchanged.
+// A symlinked target is resolved first, so the file it points to is replaced rather than the link.
+// Targets without the owner write permission are refused, even though renaming would succeed.
+func WriteFileWithMode(content, filePath string, mode os.FileMode) error {
+	resolvedPath, err := filepath.EvalSymlinks(filePath)
+	if err == nil {
+		filePath = resolvedPath
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	info, err := os.Stat(filePath)
+	if err == nil {
+		// Renaming would silently replace a file the user protected against writes
+		if info.Mode().Perm()&0200 == 0 {
+			return &os.PathError{Op: "write", Path: filePath, Err: os.ErrPermission}
+		}
+		existingContent, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		if string(existingContent) == content {
+			if info.Mode().Perm() == mode {
+				return nil
+			}
+			return os.Chmod(filePath, mode)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	dir := filepath.Dir(filePath)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(dir, fmt.Sprintf(".%s.tmp-*", filepath.Base(filePath)))
+	if err != nil {
+		return err
+	}
+	// Removing the temporary file fails harmlessly once it has been renamed
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.WriteString(content)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// The temporary file is created with 0600 regardless of the umask
+	err = os.Chmod(tmpFile.Name(), mode)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpFile.Name(), filePath)
+	if err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes the directory entry of a renamed file. Not every platform supports
+// syncing directories, so failures are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
+
+func AppendFile(content, filePath string) error {
+	// Open the file for appending, creating it if it doesn't exist
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	// Convert the string to a byte slice
-	contentBytes := []byte(content)
-	// Write the content to the file
-	_, err = file.Write(contentBytes)
+	_, err = file.WriteString(content)
 	return err
 }
 
@@ -51,3 +135,33 @@ func ReadFile(filename string) (string, error) {
 	_, err = file.Read(content)
 	return string(content), err
 }
+
+// GlobToRegexp converts a glob pattern into an anchored regular expression.
+// '*' and '?' do not match path separators, while '**' matches across them.
+func GlobToRegexp(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// '**/' also matches zero directories
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					expr.WriteString("(.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
@@ -3,7 +3,9 @@ package helpers
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -98,8 +100,8 @@ func TestWriteFile(t *testing.T) {
 	contentBytes, err := os.ReadFile(testFilePath)
 	assert.NoError(t, err, "Expected no error")
 	assert.Equal(t, testContent, string(contentBytes), "File content does not match")
-	// Test writing to an invalid file path
-	invalidFilePath := "/nonexistentfolder/testfile.txt"
+	// Test writing to an invalid file path (the parent is a file, so it can't be created)
+	invalidFilePath := filepath.Join(testFilePath, "testfile.txt")
 	err = WriteFile(testContent, invalidFilePath)
 	assert.Error(t, err, "Expected an error")
 	// Test writing with a permission-denied scenario (simulate by creating a read-only file)
@@ -113,6 +115,73 @@ func TestWriteFile(t *testing.T) {
 	assert.Error(t, err, "Expected an error")
 }
 
+func TestWriteFileWithMode(t *testing.T) {
+	dir := t.TempDir()
+	testFilePath := filepath.Join(dir, "nested", "folder", "testfile.txt")
+
+	// Missing parent directories are created and the mode is applied
+	err := WriteFileWithMode("Hello, world!", testFilePath, 0600)
+	assert.NoError(t, err, "Expected no error")
+	info, err := os.Stat(testFilePath)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "File mode does not match")
+
+	// Unchanged content is not written again
+	oldTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err = os.Chtimes(testFilePath, oldTime, oldTime)
+	assert.NoError(t, err, "Expected no error")
+	err = WriteFileWithMode("Hello, world!", testFilePath, 0600)
+	assert.NoError(t, err, "Expected no error")
+	info, err = os.Stat(testFilePath)
+	assert.NoError(t, err, "Expected no error")
+	assert.True(t, info.ModTime().Equal(oldTime), "Unchanged file was rewritten")
+
+	// Unchanged content with a different mode only updates the mode
+	err = WriteFileWithMode("Hello, world!", testFilePath, 0644)
+	assert.NoError(t, err, "Expected no error")
+	info, err = os.Stat(testFilePath)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm(), "File mode does not match")
+	assert.True(t, info.ModTime().Equal(oldTime), "Unchanged file was rewritten")
+
+	// Changed content replaces the file without leaving temporary files behind
+	err = WriteFileWithMode("Hello, again!", testFilePath, 0644)
+	assert.NoError(t, err, "Expected no error")
+	contentBytes, err := os.ReadFile(testFilePath)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, "Hello, again!", string(contentBytes), "File content does not match")
+	entries, err := os.ReadDir(filepath.Dir(testFilePath))
+	assert.NoError(t, err, "Expected no error")
+	assert.Len(t, entries, 1, "Temporary file was left behind")
+
+	// A symlinked target is kept and the file it points to is replaced
+	linkPath := filepath.Join(dir, "link.txt")
+	assert.NoError(t, os.Symlink(testFilePath, linkPath), "Expected no error")
+	err = WriteFileWithMode("Hello, link!", linkPath, 0644)
+	assert.NoError(t, err, "Expected no error")
+	linkInfo, err := os.Lstat(linkPath)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, os.ModeSymlink, linkInfo.Mode()&os.ModeSymlink, "Symlink was replaced")
+	contentBytes, err = os.ReadFile(testFilePath)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, "Hello, link!", string(contentBytes), "File content does not match")
+}
+
+func TestAppendFile(t *testing.T) {
+	testFilePath := filepath.Join(t.TempDir(), "testfile.txt")
+	// Appending to a missing file creates it
+	err := AppendFile("Hello, ", testFilePath)
+	assert.NoError(t, err, "Expected no error")
+	err = AppendFile("world!", testFilePath)
+	assert.NoError(t, err, "Expected no error")
+	contentBytes, err := os.ReadFile(testFilePath)
+	assert.NoError(t, err, "Expected no error")
+	assert.Equal(t, "Hello, world!", string(contentBytes), "File content does not match")
+	// Test appending to an invalid file path
+	err = AppendFile("content", "/nonexistentfolder/testfile.txt")
+	assert.Error(t, err, "Expected an error")
+}
+
 func TestLookupEnvString(t *testing.T) {
 	// Set up environment variables for testing
 	key := "TEST_KEY"
@@ -175,3 +244,57 @@ func TestReadFile_ReadError(t *testing.T) {
 	_, err = ReadFile(tempFile.Name())
 	assert.Error(t, err) // Check that an error is returned
 }
+
+func TestGlobToRegexp(t *testing.T) {
+	testCases := []struct {
+		name          string
+		pattern       string
+		value         string
+		expectedValue bool
+	}{
+		{
+			name:          "SingleStarSameLevel",
+			pattern:       "k8s/*",
+			value:         "k8s/app",
+			expectedValue: true,
+		},
+		{
+			name:          "SingleStarNestedLevel",
+			pattern:       "k8s/*",
+			value:         "k8s/app/dev",
+			expectedValue: false,
+		},
+		{
+			name:          "DoubleStarNestedLevel",
+			pattern:       "k8s/**",
+			value:         "k8s/app/dev",
+			expectedValue: true,
+		},
+		{
+			name:          "DoubleStarZeroDirectories",
+			pattern:       "**/prod",
+			value:         "prod",
+			expectedValue: true,
+		},
+		{
+			name:          "QuestionMark",
+			pattern:       "app-?",
+			value:         "app-1",
+			expectedValue: true,
+		},
+		{
+			name:          "EscapedCharacters",
+			pattern:       "app.prod",
+			value:         "app-prod",
+			expectedValue: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			re, err := GlobToRegexp(tc.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedValue, re.MatchString(tc.value))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package scm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/totmicro/atlantis-yaml-generator/pkg/helpers"
+)
+
+// Change types of a changed file, normalized across SCMs.
+const (
+	StatusAdded    = "added"
+	StatusModified = "modified"
+	StatusRemoved  = "removed"
+	StatusRenamed  = "renamed"
+)
+
+// ChangedFile is a file changed by a change request (pull request, merge request...).
+// PreviousPath is only set for renamed files.
+type ChangedFile struct {
+	Path         string
+	Status       string
+	PreviousPath string
+}
+
+// ChangeProvider lists the files changed by a change request.
+type ChangeProvider interface {
+	ChangedFiles() ([]ChangedFile, error)
+}
+
+// ProviderFunc adapts a function to the ChangeProvider interface.
+type ProviderFunc func() ([]ChangedFile, error)
+
+// ChangedFiles calls f.
+func (f ProviderFunc) ChangedFiles() ([]ChangedFile, error) {
+	return f()
+}
+
+// Paths returns the paths touched by the changed files. Renamed files
+// touch both their new and previous paths.
+func Paths(files []ChangedFile) (paths []string) {
+	for _, file := range files {
+		paths = append(paths, file.Path)
+		if file.PreviousPath != "" && file.PreviousPath != file.Path {
+			paths = append(paths, file.PreviousPath)
+		}
+	}
+	return paths
+}
+
+// FileProvider is a ChangeProvider reading the changed files from a file, either a path
+// per line or the `git diff --name-status` output. It is meant for tests and for CI
+// systems that compute the changes themselves.
+type FileProvider struct {
+	Path string
+}
+
+// ChangedFiles parses the changed files of the file.
+func (p FileProvider) ChangedFiles() ([]ChangedFile, error) {
+	if p.Path == "" {
+		return nil, errors.New("changed-files is not set.\n" +
+			"Please use changed-files parameter or CHANGED_FILES environment variable to set the changed files list.")
+	}
+	content, err := helpers.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	return parseChangedFiles(content)
+}
+
+// parseChangedFiles parses a changed files list. Lines with tab separated fields
+// follow the `git diff --name-status` format, other lines are modified paths.
+func parseChangedFiles(content string) (files []ChangedFile, err error) {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 1 {
+			files = append(files, ChangedFile{Path: line, Status: StatusModified})
+			continue
+		}
+		// Rename and copy statuses carry a similarity score, i.e. R090
+		switch fields[0][:1] {
+		case "A":
+			files = append(files, ChangedFile{Path: fields[1], Status: StatusAdded})
+		case "M", "T":
+			files = append(files, ChangedFile{Path: fields[1], Status: StatusModified})
+		case "D":
+			files = append(files, ChangedFile{Path: fields[1], Status: StatusRemoved})
+		case "R", "C":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("invalid changed file line '%s'", line)
+			}
+			if fields[0][:1] == "C" {
+				files = append(files, ChangedFile{Path: fields[2], Status: StatusAdded})
+				continue
+			}
+			files = append(files, ChangedFile{Path: fields[2], Status: StatusRenamed, PreviousPath: fields[1]})
+		default:
+			return nil, fmt.Errorf("invalid changed file status '%s'", fields[0])
+		}
+	}
+	return files, nil
+}
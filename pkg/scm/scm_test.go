@@ -0,0 +1,52 @@
+package scm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaths(t *testing.T) {
+	files := []ChangedFile{
+		{Path: "app/main.tf", Status: StatusModified},
+		{Path: "db/main.tf", Status: StatusRenamed, PreviousPath: "old/main.tf"},
+		{Path: "network/main.tf", Status: StatusRemoved},
+	}
+	assert.Equal(t, []string{"app/main.tf", "db/main.tf", "old/main.tf", "network/main.tf"}, Paths(files))
+	assert.Empty(t, Paths(nil))
+}
+
+func TestFileProvider(t *testing.T) {
+	changedFilesPath := filepath.Join(t.TempDir(), "changed-files.txt")
+	content := "app/main.tf\n" +
+		"A\tdb/main.tf\n" +
+		"D\tnetwork/main.tf\n" +
+		"R090\told/main.tf\tnew/main.tf\n" +
+		"C100\tapp/main.tf\tcopy/main.tf\n" +
+		"\n"
+	assert.NoError(t, os.WriteFile(changedFilesPath, []byte(content), 0644))
+
+	var provider ChangeProvider = FileProvider{Path: changedFilesPath}
+	files, err := provider.ChangedFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []ChangedFile{
+		{Path: "app/main.tf", Status: StatusModified},
+		{Path: "db/main.tf", Status: StatusAdded},
+		{Path: "network/main.tf", Status: StatusRemoved},
+		{Path: "new/main.tf", Status: StatusRenamed, PreviousPath: "old/main.tf"},
+		{Path: "copy/main.tf", Status: StatusAdded},
+	}, files)
+
+	_, err = FileProvider{Path: filepath.Join(t.TempDir(), "missing.txt")}.ChangedFiles()
+	assert.Error(t, err)
+}
+
+func TestParseChangedFilesErrors(t *testing.T) {
+	_, err := parseChangedFiles("X\tapp/main.tf")
+	assert.Error(t, err)
+
+	_, err = parseChangedFiles("R100\tapp/main.tf")
+	assert.Error(t, err)
+}